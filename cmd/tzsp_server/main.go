@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"regexp"
 	"syscall"
+	"time"
 
+	"github.com/pavelkim/tzsp_server/internal/anomaly"
+	"github.com/pavelkim/tzsp_server/internal/capture"
 	"github.com/pavelkim/tzsp_server/internal/config"
+	"github.com/pavelkim/tzsp_server/internal/control"
+	"github.com/pavelkim/tzsp_server/internal/filter"
 	"github.com/pavelkim/tzsp_server/internal/logger"
 	"github.com/pavelkim/tzsp_server/internal/netflow"
 	"github.com/pavelkim/tzsp_server/internal/output"
+	"github.com/pavelkim/tzsp_server/internal/parsers"
 	"github.com/pavelkim/tzsp_server/internal/pcap"
 	"github.com/pavelkim/tzsp_server/internal/qingping"
+	"github.com/pavelkim/tzsp_server/internal/reassembly"
 	"github.com/pavelkim/tzsp_server/internal/server"
+	"github.com/pavelkim/tzsp_server/internal/session"
 	"github.com/pavelkim/tzsp_server/internal/version"
 )
 
@@ -22,10 +32,22 @@ func main() {
 	// Parse command line flags
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
 	showVersion := flag.Bool("version", false, "Show version and exit")
+	versionJSON := flag.Bool("json", false, "With --version, print build info as JSON")
 	flag.Parse()
 
 	if *showVersion {
-		fmt.Printf("tzsp_server version %s\n", version.GetVersion())
+		info := version.GetInfo()
+		if *versionJSON {
+			data, err := json.MarshalIndent(info, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to marshal version info: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		} else {
+			fmt.Printf("tzsp_server version %s (commit %s, built %s, %s, %s/%s)\n",
+				info.Version, info.Commit, info.BuildDate, info.GoVersion, info.OS, info.Arch)
+		}
 		os.Exit(0)
 	}
 
@@ -38,55 +60,77 @@ func main() {
 
 	// Initialize logger
 
-	       logCfg := &logger.Config{
-		       File: logger.FileConfig{
-			       Enabled: cfg.Logging.File.Enabled,
-			       Level:   cfg.Logging.File.Level,
-			       Format:  cfg.Logging.File.Format,
-			       Path:    cfg.Logging.File.Path,
-		       },
-		       Console: logger.ConsoleConfig{
-			       Enabled: cfg.Logging.Console.Enabled,
-			       Level:   cfg.Logging.Console.Level,
-			       Format:  cfg.Logging.Console.Format,
-		       },
-	       }
-	       log, err := logger.NewLogger(logCfg)
-	       if err != nil {
-		       fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
-		       os.Exit(1)
-	       }
-
-	       log.Info("========================================")
-	       log.Info("Starting TZSP Server", "version", version.GetVersion())
-	       log.Info("========================================")
-	       log.Info("Configuration loaded", "file", *configPath)
-	       log.Info("Server settings",
-		       "listen_addr", cfg.Server.ListenAddr,
-		       "buffer_size", cfg.Server.BufferSize)
-
-	       // Print enabled logging destinations
-	       if logCfg.Console.Enabled {
-		       log.Info("Logging destination: CONSOLE",
-			       "level", logCfg.Console.Level,
-			       "format", logCfg.Console.Format)
-	       }
-	       if logCfg.File.Enabled && logCfg.File.Path != "" {
-		       log.Info("Logging destination: FILE",
-			       "level", logCfg.File.Level,
-			       "format", logCfg.File.Format,
-			       "path", logCfg.File.Path)
-	       }
+	logCfg := &logger.Config{
+		File: logger.FileConfig{
+			Enabled: cfg.Logging.File.Enabled,
+			Level:   cfg.Logging.File.Level,
+			Format:  cfg.Logging.File.Format,
+			Path:    cfg.Logging.File.Path,
+		},
+		Console: logger.ConsoleConfig{
+			Enabled: cfg.Logging.Console.Enabled,
+			Level:   cfg.Logging.Console.Level,
+			Format:  cfg.Logging.Console.Format,
+		},
+	}
+	log, err := logger.NewLogger(logCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	log.Info("========================================")
+	buildInfo := version.GetInfo()
+	log.Info("Starting TZSP Server",
+		"version", buildInfo.Version,
+		"commit", buildInfo.Commit,
+		"build_date", buildInfo.BuildDate,
+		"go_version", buildInfo.GoVersion,
+		"os", buildInfo.OS,
+		"arch", buildInfo.Arch)
+	log.Info("========================================")
+	log.Info("Configuration loaded", "file", *configPath)
+	log.Info("Server settings",
+		"listen_addr", cfg.Server.ListenAddr,
+		"buffer_size", cfg.Server.BufferSize,
+		"capture_mode", cfg.Server.Capture.Mode,
+		"metrics_addr", cfg.Server.MetricsAddr,
+		"workers", cfg.Server.Workers,
+		"queue_depth", cfg.Server.QueueDepth,
+		"overflow_policy", cfg.Server.OverflowPolicy)
+
+	// Validate the capture mode up front. For xdp mode this only runs
+	// capture.NewXDPCapture's configuration checks (it fails on non-Linux
+	// builds and when no interface is set); no XDP capture is started, and
+	// the server below receives packets over the UDP listener in every mode.
+	if cfg.Server.Capture.Mode == "xdp" {
+		if _, err := capture.NewXDPCapture(cfg.Server.Capture.EBPF.Interface, cfg.Server.Capture.EBPF.Filter); err != nil {
+			log.Error("Invalid capture configuration", "error", err)
+			os.Exit(1)
+		}
+	} else if cfg.Server.Capture.Mode != "udp" {
+		log.Error("Unknown capture mode", "mode", cfg.Server.Capture.Mode)
+		os.Exit(1)
+	}
+
+	// Print enabled logging destinations
+	if logCfg.Console.Enabled {
+		log.Info("Logging destination: CONSOLE",
+			"level", logCfg.Console.Level,
+			"format", logCfg.Console.Format)
+	}
+	if logCfg.File.Enabled && logCfg.File.Path != "" {
+		log.Info("Logging destination: FILE",
+			"level", logCfg.File.Level,
+			"format", logCfg.File.Format,
+			"path", logCfg.File.Path)
+	}
 
 	// Initialize file output for packet metadata if enabled
 	var fileWriter *output.FileWriter
 	if cfg.Output.File.Enabled {
 		log.Info("Initializing file output for packet metadata...")
-		fileWriter, err = output.NewFileWriter(
-			cfg.Output.File.Enabled,
-			cfg.Output.File.OutputFile,
-			cfg.Output.File.Format,
-		)
+		fileWriter, err = newFileWriter(cfg)
 		if err != nil {
 			log.Error("Failed to initialize file output", "error", err)
 			os.Exit(1)
@@ -103,11 +147,7 @@ func main() {
 	var pcapWriter *pcap.Writer
 	if cfg.Output.PCAP.Enabled {
 		log.Info("Initializing PCAP writer...")
-		pcapWriter, err = pcap.NewWriter(
-			cfg.Output.PCAP.OutputFile,
-			cfg.Output.PCAP.MaxSizeMB,
-			cfg.Output.PCAP.MaxBackups,
-		)
+		pcapWriter, err = newPcapWriter(cfg)
 		if err != nil {
 			log.Error("Failed to initialize PCAP writer", "error", err)
 			os.Exit(1)
@@ -115,6 +155,7 @@ func main() {
 		defer pcapWriter.Close()
 		log.Info("[OK] PCAP writer initialized",
 			"file", cfg.Output.PCAP.OutputFile,
+			"format", cfg.Output.PCAP.Format,
 			"max_size_mb", cfg.Output.PCAP.MaxSizeMB,
 			"max_backups", cfg.Output.PCAP.MaxBackups)
 	} else {
@@ -125,12 +166,7 @@ func main() {
 	var netflowExp *netflow.Exporter
 	if cfg.Output.NetFlow.Enabled {
 		log.Info("Initializing NetFlow exporter...")
-		netflowExp, err = netflow.NewExporter(
-			cfg.Output.NetFlow.CollectorAddr,
-			cfg.Output.NetFlow.Version,
-			cfg.Output.NetFlow.FlowTimeout,
-			cfg.Output.NetFlow.ActiveTimeout,
-		)
+		netflowExp, err = newNetflowExporter(cfg)
 		if err != nil {
 			log.Error("Failed to initialize NetFlow exporter", "error", err)
 			os.Exit(1)
@@ -140,7 +176,10 @@ func main() {
 			"collector", cfg.Output.NetFlow.CollectorAddr,
 			"version", cfg.Output.NetFlow.Version,
 			"flow_timeout", cfg.Output.NetFlow.FlowTimeout,
-			"active_timeout", cfg.Output.NetFlow.ActiveTimeout)
+			"active_timeout", cfg.Output.NetFlow.ActiveTimeout,
+			"mtu", cfg.Output.NetFlow.MTU,
+			"observation_domain_id", cfg.Output.NetFlow.ObservationDomainID,
+			"bidirectional", cfg.Output.NetFlow.Bidirectional)
 	} else {
 		log.Info("NetFlow exporter disabled")
 	}
@@ -149,20 +188,7 @@ func main() {
 	var qingpingExp *qingping.Exporter
 	if cfg.Output.QingPing.Enabled {
 		log.Info("Initializing QingPing exporter...")
-		qingpingExp, err = qingping.NewExporter(qingping.Config{
-			Enabled: cfg.Output.QingPing.Enabled,
-			Filter: qingping.Filter{
-				SrcIP:    cfg.Output.QingPing.Filter.SrcIP,
-				DstIP:    cfg.Output.QingPing.Filter.DstIP,
-				DstPort:  cfg.Output.QingPing.Filter.DstPort,
-				Protocol: cfg.Output.QingPing.Filter.Protocol,
-			},
-			StrictJSON:       cfg.Output.QingPing.StrictJSON,
-			UpstreamURL:      cfg.Output.QingPing.UpstreamURL,
-			IgnoreSSL:        cfg.Output.QingPing.IgnoreSSL,
-			IgnoreHTTPErrors: cfg.Output.QingPing.IgnoreHTTPErrors,
-			Logger:           log,
-		})
+		qingpingExp, err = newQingpingExporter(cfg, log)
 		if err != nil {
 			log.Error("Failed to initialize QingPing exporter", "error", err)
 			os.Exit(1)
@@ -173,19 +199,126 @@ func main() {
 		log.Info("QingPing exporter disabled")
 	}
 
+	// Initialize the anomaly capture engine if enabled
+	var anomalyEngine *anomaly.Engine
+	if cfg.Output.Anomaly.Enabled {
+		log.Info("Initializing anomaly capture engine...")
+		anomalyEngine, err = newAnomalyEngine(cfg, log)
+		if err != nil {
+			log.Error("Failed to initialize anomaly capture engine", "error", err)
+			os.Exit(1)
+		}
+		defer anomalyEngine.Close()
+		log.Info("[OK] Anomaly capture engine initialized",
+			"triggers", len(cfg.Output.Anomaly.Triggers))
+	} else {
+		log.Info("Anomaly capture engine disabled")
+	}
+
+	// Initialize the standalone reassembly/fingerprinting output if enabled
+	var reassemblyMgr *reassembly.Manager
+	var reassemblyWriter reassembly.Writer
+	if cfg.Output.Reassembly.Enabled {
+		log.Info("Initializing reassembly output...")
+		reassemblyMgr, reassemblyWriter, err = newReassemblyOutput(cfg)
+		if err != nil {
+			log.Error("Failed to initialize reassembly output", "error", err)
+			os.Exit(1)
+		}
+		defer reassemblyMgr.Close()
+		defer reassemblyWriter.Close()
+		log.Info("[OK] Reassembly output initialized",
+			"http", cfg.Output.Reassembly.EnableHTTP,
+			"tls", cfg.Output.Reassembly.EnableTLS,
+			"file", cfg.Output.Reassembly.OutputFile)
+	} else {
+		log.Info("Reassembly output disabled")
+	}
+
+	// Build the L7 parser registry: built-ins are always registered, then
+	// any configured plugins are loaded on top.
+	log.Info("Initializing L7 parser registry...")
+	parserRegistry, err := newParserRegistry(cfg)
+	if err != nil {
+		log.Error("Failed to initialize L7 parser registry", "error", err)
+		os.Exit(1)
+	}
+	log.Info("[OK] L7 parser registry initialized", "plugins", len(cfg.Parsers.Plugins))
+
+	// Initialize the request/response session tracker if tracing is enabled
+	sessionTracker, err := newSessionTracker(cfg, log)
+	if err != nil {
+		log.Error("Failed to initialize session tracker", "error", err)
+		os.Exit(1)
+	}
+	if sessionTracker != nil {
+		defer sessionTracker.Close()
+		log.Info("[OK] Session tracker initialized",
+			"service_name", cfg.Output.Tracing.ServiceName,
+			"otlp_endpoint", cfg.Output.Tracing.OTLPEndpoint,
+			"session_idle_timeout", cfg.Output.Tracing.SessionIdleTimeout)
+	} else {
+		log.Info("Session tracker disabled")
+	}
+
+	// Compile each output's bpf_filter, plus the server-wide pre-filter, if configured
+	fileFilter, pcapFilter, netflowFilter, qingpingFilter, globalFilter, err := buildFilters(cfg)
+	if err != nil {
+		log.Error("Failed to compile bpf_filter", "error", err)
+		os.Exit(1)
+	}
+
 	// Create server
 	log.Info("Creating TZSP server...")
 	srv := server.NewServer(&server.Config{
-		ListenAddr:  cfg.Server.ListenAddr,
-		BufferSize:  cfg.Server.BufferSize,
-		FileWriter:  fileWriter,
-		PcapWriter:  pcapWriter,
-		NetFlowExp:  netflowExp,
-		QingPingExp: qingpingExp,
-		Logger:      log,
+		ListenAddr:            cfg.Server.ListenAddr,
+		BufferSize:            cfg.Server.BufferSize,
+		FileWriter:            fileWriter,
+		PcapWriter:            pcapWriter,
+		NetFlowExp:            netflowExp,
+		QingPingExp:           qingpingExp,
+		AnomalyEngine:         anomalyEngine,
+		ReassemblyIdleTimeout: time.Duration(cfg.Output.QingPing.ReassemblyIdleTimeout) * time.Second,
+		FileFilter:            fileFilter,
+		PcapFilter:            pcapFilter,
+		NetflowFilter:         netflowFilter,
+		QingpingFilter:        qingpingFilter,
+		GlobalFilter:          globalFilter,
+		DenySourceIPs:         cfg.Server.DenySourceIPs,
+		SampleRate:            cfg.Server.SampleRate,
+		Parsers:               parserRegistry,
+		SessionTracker:        sessionTracker,
+		MetricsAddr:           cfg.Server.MetricsAddr,
+		Workers:               cfg.Server.Workers,
+		QueueDepth:            cfg.Server.QueueDepth,
+		OverflowPolicy:        server.OverflowPolicy(cfg.Server.OverflowPolicy),
+		ReassemblyMgr:         reassemblyMgr,
+		ReassemblyWriter:      reassemblyWriter,
+		Logger:                log,
 	})
 	log.Info("[OK] Server created successfully")
 
+	// Watch the config file for changes (SIGHUP or on-disk edits) and hot
+	// swap the output sinks without restarting the UDP listener.
+	watcher, err := config.NewWatcher(*configPath)
+	if err != nil {
+		log.Error("Failed to start config watcher, hot-reload disabled", "error", err)
+	} else {
+		defer watcher.Close()
+		go watchConfig(watcher, srv, log)
+
+		// The control socket's "reload" command and "enable netflow"
+		// defaults both need the watcher, so it's only built when the
+		// watcher started successfully.
+		controlSrv, err := newControlServer(cfg, watcher, srv, log)
+		if err != nil {
+			log.Error("Failed to start control socket", "error", err)
+		} else if controlSrv != nil {
+			defer controlSrv.Close()
+			log.Info("[OK] Control socket listening", "path", cfg.Server.ControlSocket)
+		}
+	}
+
 	// Setup graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -224,3 +357,390 @@ func main() {
 	log.Info("TZSP Server terminated")
 	log.Info("========================================")
 }
+
+// applyConfigReload rebuilds every output sink and filter from cfg and
+// swaps them into srv, used by both the file/SIGHUP watcher and the
+// control socket's "reload" command. ListenAddr/BufferSize changes can't
+// be applied this way (the UDP socket isn't restarted), so those are only
+// logged.
+func applyConfigReload(cfg *config.Config, srv *server.Server, log *logger.Logger) error {
+	if srv.ListenConfigChanged(cfg.Server.ListenAddr, cfg.Server.BufferSize) {
+		log.Warn("Config changed listen_addr/buffer_size; these require a process restart and were NOT applied",
+			"listen_addr", cfg.Server.ListenAddr,
+			"buffer_size", cfg.Server.BufferSize)
+	}
+
+	fileWriter, err := newFileWriter(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild file output: %w", err)
+	}
+	pcapWriter, err := newPcapWriter(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild PCAP writer: %w", err)
+	}
+	netflowExp, err := newNetflowExporter(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild NetFlow exporter: %w", err)
+	}
+	qingpingExp, err := newQingpingExporter(cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild QingPing exporter: %w", err)
+	}
+	anomalyEngine, err := newAnomalyEngine(cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild anomaly capture engine: %w", err)
+	}
+	parserRegistry, err := newParserRegistry(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild L7 parser registry: %w", err)
+	}
+	sessionTracker, err := newSessionTracker(cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild session tracker: %w", err)
+	}
+	fileFilter, pcapFilter, netflowFilter, qingpingFilter, globalFilter, err := buildFilters(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to compile bpf_filter: %w", err)
+	}
+
+	srv.ReplaceOutputs(fileWriter, pcapWriter, netflowExp, qingpingExp, anomalyEngine, parserRegistry, sessionTracker,
+		time.Duration(cfg.Output.QingPing.ReassemblyIdleTimeout)*time.Second,
+		fileFilter, pcapFilter, netflowFilter, qingpingFilter, globalFilter,
+		cfg.Server.DenySourceIPs, cfg.Server.SampleRate)
+
+	if err := log.Reopen(); err != nil {
+		log.Error("Config reload: failed to reopen log file", "error", err)
+	}
+
+	return nil
+}
+
+// watchConfig drains watcher's reload channels for the lifetime of the
+// process, rebuilding the output sinks and swapping them into srv on every
+// successful reload. ListenAddr/BufferSize changes can't be applied this
+// way (the UDP socket isn't restarted), so those are only logged.
+func watchConfig(watcher *config.Watcher, srv *server.Server, log *logger.Logger) {
+	for {
+		select {
+		case cfg, ok := <-watcher.Changes():
+			if !ok {
+				return
+			}
+			log.Info("Config reload detected, reloading outputs...")
+
+			if err := applyConfigReload(cfg, srv, log); err != nil {
+				log.Error("Config reload failed, keeping previous sinks", "error", err)
+				continue
+			}
+
+			log.Info("[OK] Config reload applied")
+
+		case err, ok := <-watcher.Errors():
+			if !ok {
+				return
+			}
+			log.Error("Config reload failed", "error", err)
+		}
+	}
+}
+
+// newFileWriter builds the file output sink from cfg, or returns a nil
+// sink without error when it's disabled.
+func newFileWriter(cfg *config.Config) (*output.FileWriter, error) {
+	if !cfg.Output.File.Enabled {
+		return nil, nil
+	}
+	return output.NewFileWriter(
+		cfg.Output.File.Enabled,
+		cfg.Output.File.OutputFile,
+		cfg.Output.File.Format,
+	)
+}
+
+// newPcapWriter builds the PCAP output sink from cfg, or returns a nil
+// sink without error when it's disabled.
+func newPcapWriter(cfg *config.Config) (*pcap.Writer, error) {
+	if !cfg.Output.PCAP.Enabled {
+		return nil, nil
+	}
+	return pcap.NewWriter(
+		cfg.Output.PCAP.OutputFile,
+		cfg.Output.PCAP.MaxSizeMB,
+		cfg.Output.PCAP.MaxBackups,
+		cfg.Output.PCAP.Format,
+	)
+}
+
+// newNetflowExporter builds the NetFlow exporter from cfg, or returns a
+// nil sink without error when it's disabled.
+func newNetflowExporter(cfg *config.Config) (*netflow.Exporter, error) {
+	if !cfg.Output.NetFlow.Enabled {
+		return nil, nil
+	}
+	return netflow.NewExporter(
+		cfg.Output.NetFlow.CollectorAddr,
+		cfg.Output.NetFlow.Version,
+		cfg.Output.NetFlow.FlowTimeout,
+		cfg.Output.NetFlow.ActiveTimeout,
+		cfg.Output.NetFlow.MTU,
+		cfg.Output.NetFlow.ObservationDomainID,
+		cfg.Output.NetFlow.Bidirectional,
+	)
+}
+
+// newQingpingExporter builds the QingPing exporter from cfg, or returns a
+// nil sink without error when it's disabled.
+func newQingpingExporter(cfg *config.Config, log *logger.Logger) (*qingping.Exporter, error) {
+	if !cfg.Output.QingPing.Enabled {
+		return nil, nil
+	}
+	return qingping.NewExporter(qingping.Config{
+		Enabled:    cfg.Output.QingPing.Enabled,
+		StrictJSON: cfg.Output.QingPing.StrictJSON,
+		Sinks:      qingpingSinkConfigs(cfg.Output.QingPing.Sinks),
+		Logger:     log,
+	})
+}
+
+// qingpingSinkConfigs translates the config package's flat per-type sink
+// settings into qingping.SinkConfig values.
+func qingpingSinkConfigs(sinks []config.QingPingSinkConfig) []qingping.SinkConfig {
+	out := make([]qingping.SinkConfig, 0, len(sinks))
+	for _, sc := range sinks {
+		out = append(out, qingping.SinkConfig{
+			Name:         sc.Name,
+			Type:         sc.Type,
+			QueueSize:    sc.QueueSize,
+			Backpressure: qingping.BackpressurePolicy(sc.Backpressure),
+			HTTP: qingping.HTTPSinkConfig{
+				UpstreamURL:      sc.HTTP.UpstreamURL,
+				IgnoreSSL:        sc.HTTP.IgnoreSSL,
+				IgnoreHTTPErrors: sc.HTTP.IgnoreHTTPErrors,
+			},
+			MQTT: qingping.MQTTSinkConfig{
+				BrokerAddr:    sc.MQTT.BrokerAddr,
+				ClientID:      sc.MQTT.ClientID,
+				TopicTemplate: sc.MQTT.TopicTemplate,
+			},
+			File: qingping.FileSinkConfig{
+				OutputFile: sc.File.OutputFile,
+				MaxSizeMB:  sc.File.MaxSizeMB,
+				MaxBackups: sc.File.MaxBackups,
+			},
+			Kafka: qingping.KafkaSinkConfig{
+				BrokerAddr: sc.Kafka.BrokerAddr,
+				Topic:      sc.Kafka.Topic,
+				ClientID:   sc.Kafka.ClientID,
+			},
+		})
+	}
+	return out
+}
+
+// newReassemblyOutput builds the standalone reassembly/fingerprinting
+// output (independent of QingPing's own internal reassembly use) from
+// cfg, or returns nil values without error when it's disabled.
+func newReassemblyOutput(cfg *config.Config) (*reassembly.Manager, reassembly.Writer, error) {
+	if !cfg.Output.Reassembly.Enabled {
+		return nil, nil, nil
+	}
+
+	writer, err := reassembly.NewFileWriter(reassembly.FileWriterConfig{
+		OutputFile: cfg.Output.Reassembly.OutputFile,
+		MaxSizeMB:  cfg.Output.Reassembly.MaxSizeMB,
+		MaxBackups: cfg.Output.Reassembly.MaxBackups,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mgr := reassembly.NewManager(reassembly.Config{
+		IdleTimeout:    time.Duration(cfg.Output.Reassembly.IdleTimeoutSeconds) * time.Second,
+		MaxBufferBytes: cfg.Output.Reassembly.MaxStreamMemoryBytes,
+		EnableHTTP:     cfg.Output.Reassembly.EnableHTTP,
+		EnableTLS:      cfg.Output.Reassembly.EnableTLS,
+	})
+
+	return mgr, writer, nil
+}
+
+// newAnomalyEngine builds the anomaly capture engine from cfg, or returns
+// a nil engine without error when it's disabled.
+func newAnomalyEngine(cfg *config.Config, log *logger.Logger) (*anomaly.Engine, error) {
+	if !cfg.Output.Anomaly.Enabled {
+		return nil, nil
+	}
+
+	triggers := make([]anomaly.TriggerConfig, 0, len(cfg.Output.Anomaly.Triggers))
+	for _, tc := range cfg.Output.Anomaly.Triggers {
+		trigger := anomaly.TriggerConfig{
+			Name:               tc.Name,
+			Protocol:           tc.Protocol,
+			MinPort:            tc.MinPort,
+			MaxPort:            tc.MaxPort,
+			TCPFlagsMask:       anomaly.ParseTCPFlags(tc.TCPFlagsMask),
+			TCPFlagsMatch:      anomaly.ParseTCPFlags(tc.TCPFlagsMatch),
+			PayloadInvalidJSON: tc.PayloadInvalidJSON,
+			MinBytes:           tc.MinBytes,
+			MaxBytes:           tc.MaxBytes,
+			MinFlowAge:         time.Duration(tc.MinFlowAgeSeconds) * time.Second,
+			MaxFlowAge:         time.Duration(tc.MaxFlowAgeSeconds) * time.Second,
+			MinRetransmits:     tc.MinRetransmits,
+			MaxPacketsPerFlow:  tc.MaxPacketsPerFlow,
+			OutputFile:         tc.OutputFile,
+			MaxSizeMB:          tc.MaxSizeMB,
+			MaxBackups:         tc.MaxBackups,
+		}
+		if tc.PayloadRegex != "" {
+			re, err := regexp.Compile(tc.PayloadRegex)
+			if err != nil {
+				return nil, fmt.Errorf("trigger %q: invalid payload_regex: %w", tc.Name, err)
+			}
+			trigger.PayloadRegex = re
+		}
+		triggers = append(triggers, trigger)
+	}
+
+	return anomaly.NewEngine(triggers, log)
+}
+
+// newParserRegistry builds the L7 parser registry: MQTT on 1883, HTTP on
+// 80/8080, raw as the protocol-wide fallback for TCP and UDP, then any
+// configured plugins loaded on top (which may override built-ins by
+// registering over the same proto/port).
+func newParserRegistry(cfg *config.Config) (*parsers.Registry, error) {
+	reg := parsers.NewRegistry()
+
+	reg.Register("TCP", 1883, parsers.NewMQTTParser())
+	reg.Register("TCP", 80, parsers.NewHTTPParser())
+	reg.Register("TCP", 8080, parsers.NewHTTPParser())
+	reg.Register("TCP", 0, parsers.NewRawParser())
+	reg.Register("UDP", 0, parsers.NewRawParser())
+
+	if len(cfg.Parsers.Plugins) > 0 {
+		if err := parsers.LoadPlugins(cfg.Parsers.Plugins, reg); err != nil {
+			return nil, err
+		}
+	}
+
+	return reg, nil
+}
+
+// newSessionTracker builds the request/response session tracker from
+// cfg, or returns a nil tracker without error when tracing is disabled.
+// Spans are exported to an OTLP collector when otlp_endpoint is set;
+// otherwise they fall back to logTracer's debug log lines.
+func newSessionTracker(cfg *config.Config, log *logger.Logger) (*session.Tracker, error) {
+	if !cfg.Output.Tracing.Enabled {
+		return nil, nil
+	}
+
+	var tracer session.Tracer
+	if cfg.Output.Tracing.OTLPEndpoint != "" {
+		serviceName := cfg.Output.Tracing.ServiceName
+		if serviceName == "" {
+			serviceName = "tzsp_server"
+		}
+		otlpTracer, err := session.NewOTLPTracer(context.Background(), serviceName, cfg.Output.Tracing.OTLPEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP tracer: %w", err)
+		}
+		tracer = otlpTracer
+	} else {
+		tracer = session.NewLogTracer(log)
+	}
+
+	idleTimeout := time.Duration(cfg.Output.Tracing.SessionIdleTimeout) * time.Second
+	return session.NewTracker(tracer, idleTimeout), nil
+}
+
+// buildFilters compiles each output's bpf_filter expression, plus the
+// server-wide pre-filter (cfg.Server.BPFFilter) applied ahead of all of
+// them. An empty bpf_filter yields a nil *filter.Filter, which matches
+// everything.
+func buildFilters(cfg *config.Config) (fileFilter, pcapFilter, netflowFilter, qingpingFilter, globalFilter *filter.Filter, err error) {
+	if fileFilter, err = compileFilter(cfg.Output.File.BPFFilter); err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("file output: %w", err)
+	}
+	if pcapFilter, err = compileFilter(cfg.Output.PCAP.BPFFilter); err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("pcap output: %w", err)
+	}
+	if netflowFilter, err = compileFilter(cfg.Output.NetFlow.BPFFilter); err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("netflow output: %w", err)
+	}
+	if qingpingFilter, err = compileFilter(cfg.Output.QingPing.BPFFilter); err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("qingping output: %w", err)
+	}
+	if globalFilter, err = compileFilter(cfg.Server.BPFFilter); err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("server: %w", err)
+	}
+	return fileFilter, pcapFilter, netflowFilter, qingpingFilter, globalFilter, nil
+}
+
+// compileFilter compiles expr, or returns a nil *filter.Filter (match
+// everything) when expr is empty.
+func compileFilter(expr string) (*filter.Filter, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return filter.Compile(expr)
+}
+
+// newControlServer builds the operator control socket from cfg, or
+// returns a nil server without error when it's disabled. "reload" is
+// implemented by raising SIGHUP on our own process rather than
+// duplicating applyConfigReload's caller, so it goes through exactly the
+// same path watcher's own SIGHUP handling does.
+func newControlServer(cfg *config.Config, watcher *config.Watcher, srv *server.Server, log *logger.Logger) (*control.Server, error) {
+	if cfg.Server.ControlSocket == "" {
+		return nil, nil
+	}
+
+	cmds := control.Commands{
+		Reload: func() error {
+			return syscall.Kill(os.Getpid(), syscall.SIGHUP)
+		},
+		SetFilter: func(expr string) error {
+			f, err := compileFilter(expr)
+			if err != nil {
+				return err
+			}
+			srv.SetFilter(f)
+			return nil
+		},
+		RotatePCAP: srv.RotatePCAP,
+		EnableNetflow: func(addr string) error {
+			allowed := watcher.Current().Server.ControlAllowedNetflowAddrs
+			if !netflowAddrAllowed(addr, allowed) {
+				return fmt.Errorf("addr %q is not in control_allowed_netflow_addrs", addr)
+			}
+			nf := watcher.Current().Output.NetFlow
+			exp, err := netflow.NewExporter(addr, nf.Version, nf.FlowTimeout, nf.ActiveTimeout, nf.MTU, nf.ObservationDomainID, nf.Bidirectional)
+			if err != nil {
+				return err
+			}
+			srv.SetNetflowExporter(exp)
+			return nil
+		},
+		DisableQingPing: func() error {
+			srv.SetQingpingExporter(nil)
+			return nil
+		},
+		Stats: srv.Stats,
+	}
+
+	return control.Serve(cfg.Server.ControlSocket, cmds, log)
+}
+
+// netflowAddrAllowed reports whether addr is explicitly present in
+// allowed: the control socket's "enable netflow" command otherwise lets
+// any local caller redirect flow export anywhere, so this is opt-in
+// rather than opt-out (an empty allow-list rejects every address).
+func netflowAddrAllowed(addr string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,330 @@
+// Package anomaly implements per-flow capture triggers evaluated against
+// every decoded packet: a small predicate (protocol, port range, TCP flag
+// mask, payload regex, byte-count range, flow age, retransmit count,
+// first-N-packets) that, once matched, writes the packet to a rotating
+// PCAP-NG file dedicated to that trigger and emits a structured log
+// event. It borrows the idea of "dropped-only"/anomaly capture from
+// packet trace tooling: instead of capturing everything, operators
+// register a handful of conditions worth keeping full evidence for.
+package anomaly
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pavelkim/tzsp_server/internal/decoder"
+	"github.com/pavelkim/tzsp_server/internal/logger"
+	"github.com/pavelkim/tzsp_server/internal/pcap"
+)
+
+// TriggerConfig defines one capture trigger's predicate and where its
+// matched packets are written. A packet matches a trigger when every
+// non-zero-value field below evaluates true; see Engine.Evaluate.
+type TriggerConfig struct {
+	// Name identifies this trigger in logs and is used to build its
+	// output PCAP-NG filename.
+	Name string
+
+	// Protocol, if non-empty, must equal decoder.PacketInfo.Protocol
+	// exactly (e.g. "TCP", "UDP").
+	Protocol string
+	// MinPort/MaxPort restrict matches to packets whose source or
+	// destination port falls in [MinPort, MaxPort]. Both zero means
+	// unbounded; a zero MaxPort with non-zero MinPort means "MinPort and
+	// above".
+	MinPort, MaxPort uint16
+
+	// TCPFlagsMask/TCPFlagsMatch restrict matches to TCP packets whose
+	// flags satisfy (flags & TCPFlagsMask) == TCPFlagsMatch, using the
+	// same single-letter flag encoding as decoder.Decoder.formatTCPFlags
+	// (S/A/F/R/P/U), see ParseTCPFlags. A zero TCPFlagsMask matches
+	// anything (including non-TCP packets).
+	TCPFlagsMask, TCPFlagsMatch uint8
+
+	// PayloadRegex, if set, must match the packet's payload.
+	PayloadRegex *regexp.Regexp
+	// PayloadInvalidJSON, if true, only matches packets with a non-empty
+	// payload that fails to parse as JSON -- the same condition
+	// qingping.Exporter rejects (or warns on, in lenient mode).
+	PayloadInvalidJSON bool
+
+	// MinBytes/MaxBytes restrict matches by decoder.PacketInfo.Length.
+	// A zero MaxBytes means unbounded.
+	MinBytes, MaxBytes int
+
+	// MinFlowAge/MaxFlowAge restrict matches by how long this flow has
+	// been tracked. A zero MaxFlowAge means unbounded.
+	MinFlowAge, MaxFlowAge time.Duration
+
+	// MinRetransmits requires at least this many detected TCP
+	// retransmissions (a segment that doesn't advance the sequence
+	// number) observed so far on the flow.
+	MinRetransmits int
+	// MaxPacketsPerFlow, if > 0, only matches a flow's first N packets,
+	// e.g. "first 5 packets of any new flow to port 8883".
+	MaxPacketsPerFlow int
+
+	// OutputFile, MaxSizeMB and MaxBackups configure this trigger's
+	// dedicated capture file, using internal/pcap.Writer's PCAP-NG
+	// rotation scheme.
+	OutputFile string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+// trigger wires a TriggerConfig to its own rotating PCAP-NG writer.
+type trigger struct {
+	config TriggerConfig
+	writer *pcap.Writer
+}
+
+// flowState is the per-flow bookkeeping Engine needs to evaluate
+// age/retransmit/packet-count predicates, keyed by an undirected 5-tuple.
+type flowState struct {
+	firstSeen   time.Time
+	lastSeen    time.Time
+	packets     int
+	retransmits int
+	// lastSeq remembers the last TCP sequence number observed per
+	// direction ("srcIP:srcPort"), so a later segment that doesn't
+	// advance it is counted as a retransmission.
+	lastSeq map[string]uint32
+}
+
+// flowIdleTimeout is how long a flow can go without a packet before its
+// bookkeeping is evicted; otherwise a flow that never naturally ends
+// (one side goes silent, a capture runs for days) grows e.flows forever.
+const flowIdleTimeout = 5 * time.Minute
+
+// flowSweepInterval bounds how often Evaluate pays the cost of scanning
+// e.flows for idle entries, rather than doing it on every packet.
+const flowSweepInterval = time.Minute
+
+// Match describes one trigger firing for one packet.
+type Match struct {
+	TriggerName string
+	FlowKey     string
+	Retransmits int
+	FlowAge     time.Duration
+}
+
+// Engine evaluates every registered trigger against each decoded packet.
+// It is safe for concurrent use.
+type Engine struct {
+	triggers []*trigger
+	logger   *logger.Logger
+
+	mu        sync.Mutex
+	flows     map[string]*flowState
+	lastSweep time.Time
+}
+
+// NewEngine builds an Engine from configs, opening each trigger's capture
+// file. If any fails to open, the writers already opened are closed and
+// an error is returned.
+func NewEngine(configs []TriggerConfig, log *logger.Logger) (*Engine, error) {
+	e := &Engine{logger: log, flows: make(map[string]*flowState)}
+
+	for _, cfg := range configs {
+		w, err := pcap.NewWriter(cfg.OutputFile, cfg.MaxSizeMB, cfg.MaxBackups, pcap.FormatPCAPNG)
+		if err != nil {
+			e.Close()
+			return nil, fmt.Errorf("trigger %q: failed to open capture file: %w", cfg.Name, err)
+		}
+		e.triggers = append(e.triggers, &trigger{config: cfg, writer: w})
+	}
+
+	return e, nil
+}
+
+// Evaluate updates per-flow bookkeeping for info and checks it against
+// every registered trigger, writing a PCAP-NG record and logging an event
+// for each match. The returned matches let the caller correlate a fired
+// trigger with other subsystems (e.g. netflow.Exporter.FlagAnomaly).
+func (e *Engine) Evaluate(info *decoder.PacketInfo) []Match {
+	if len(e.triggers) == 0 {
+		return nil
+	}
+
+	key := flowKey(info)
+	packetTime := time.Unix(0, info.Timestamp)
+
+	e.mu.Lock()
+	fs, ok := e.flows[key]
+	if !ok {
+		fs = &flowState{firstSeen: packetTime, lastSeq: make(map[string]uint32)}
+		e.flows[key] = fs
+	}
+	fs.packets++
+	fs.lastSeen = packetTime
+	if info.Protocol == "TCP" && info.PayloadLen > 0 {
+		dir := fmt.Sprintf("%s:%d", info.SrcIP, info.SrcPort)
+		if last, seen := fs.lastSeq[dir]; seen && info.TCPSeq <= last {
+			fs.retransmits++
+		}
+		fs.lastSeq[dir] = info.TCPSeq
+	}
+	packetIndex := fs.packets
+	retransmits := fs.retransmits
+	age := packetTime.Sub(fs.firstSeen)
+	e.sweepIdleFlows()
+	e.mu.Unlock()
+
+	var matches []Match
+	for _, t := range e.triggers {
+		if !t.config.matchesStatic(info) {
+			continue
+		}
+		if t.config.MaxPacketsPerFlow > 0 && packetIndex > t.config.MaxPacketsPerFlow {
+			continue
+		}
+		if age < t.config.MinFlowAge {
+			continue
+		}
+		if t.config.MaxFlowAge > 0 && age > t.config.MaxFlowAge {
+			continue
+		}
+		if retransmits < t.config.MinRetransmits {
+			continue
+		}
+
+		if err := t.writer.WritePacket(info.PacketData, packetTime); err != nil {
+			e.logger.Error("Anomaly trigger fired but failed to write capture",
+				"trigger", t.config.Name, "flow", key, "error", err)
+			continue
+		}
+
+		e.logger.Info("Anomaly capture trigger fired",
+			"trigger", t.config.Name,
+			"flow", key,
+			"src_ip", info.SrcIP, "src_port", info.SrcPort,
+			"dst_ip", info.DstIP, "dst_port", info.DstPort,
+			"protocol", info.Protocol,
+			"packet_index", packetIndex,
+			"retransmits", retransmits,
+			"flow_age", age,
+		)
+
+		matches = append(matches, Match{
+			TriggerName: t.config.Name,
+			FlowKey:     key,
+			Retransmits: retransmits,
+			FlowAge:     age,
+		})
+	}
+
+	return matches
+}
+
+// sweepIdleFlows drops flows nothing has fed in over flowIdleTimeout, e.g.
+// one side of a conversation going silent or a capture running for days.
+// Called from Evaluate (e.mu already held) and itself rate-limited by
+// flowSweepInterval so normal calls don't pay for a full map scan.
+func (e *Engine) sweepIdleFlows() {
+	now := time.Now()
+	if now.Sub(e.lastSweep) < flowSweepInterval {
+		return
+	}
+	e.lastSweep = now
+
+	for key, fs := range e.flows {
+		if now.Sub(fs.lastSeen) >= flowIdleTimeout {
+			delete(e.flows, key)
+		}
+	}
+}
+
+// matchesStatic evaluates the predicate fields of c that don't depend on
+// per-flow state (protocol, ports, TCP flags, payload, byte count).
+func (c *TriggerConfig) matchesStatic(info *decoder.PacketInfo) bool {
+	if c.Protocol != "" && info.Protocol != c.Protocol {
+		return false
+	}
+	if c.MinPort != 0 || c.MaxPort != 0 {
+		if !portInRange(info.SrcPort, c.MinPort, c.MaxPort) && !portInRange(info.DstPort, c.MinPort, c.MaxPort) {
+			return false
+		}
+	}
+	if c.TCPFlagsMask != 0 {
+		if ParseTCPFlags(info.TCPFlags)&c.TCPFlagsMask != c.TCPFlagsMatch {
+			return false
+		}
+	}
+	if info.Length < c.MinBytes {
+		return false
+	}
+	if c.MaxBytes != 0 && info.Length > c.MaxBytes {
+		return false
+	}
+	if c.PayloadRegex != nil && !c.PayloadRegex.Match(info.Payload) {
+		return false
+	}
+	if c.PayloadInvalidJSON {
+		if len(info.Payload) == 0 || json.Valid(info.Payload) {
+			return false
+		}
+	}
+	return true
+}
+
+// portInRange reports whether port falls in [min, max]; max == 0 means
+// unbounded above min.
+func portInRange(port, min, max uint16) bool {
+	if port < min {
+		return false
+	}
+	if max != 0 && port > max {
+		return false
+	}
+	return true
+}
+
+// flowKey identifies a flow regardless of direction, the same way
+// qingping's connKey does, so either leg of a conversation accumulates
+// the same packet count/age/retransmit state.
+func flowKey(info *decoder.PacketInfo) string {
+	a := fmt.Sprintf("%s:%d", info.SrcIP, info.SrcPort)
+	b := fmt.Sprintf("%s:%d", info.DstIP, info.DstPort)
+	if a < b {
+		return a + "-" + b
+	}
+	return b + "-" + a
+}
+
+// ParseTCPFlags decodes decoder.Decoder's single-letter TCP flag string
+// (S/A/F/R/P/U, "-" for none) into the bitmask TriggerConfig's
+// TCPFlagsMask/TCPFlagsMatch use.
+func ParseTCPFlags(flags string) uint8 {
+	var result uint8
+	for _, c := range flags {
+		switch c {
+		case 'S':
+			result |= 0x01
+		case 'A':
+			result |= 0x02
+		case 'F':
+			result |= 0x04
+		case 'R':
+			result |= 0x08
+		case 'P':
+			result |= 0x10
+		case 'U':
+			result |= 0x20
+		}
+	}
+	return result
+}
+
+// Close closes every trigger's capture file.
+func (e *Engine) Close() error {
+	var firstErr error
+	for _, t := range e.triggers {
+		if err := t.writer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
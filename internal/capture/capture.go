@@ -0,0 +1,35 @@
+// Package capture abstracts how encapsulated packets reach the server:
+// the existing UDP TZSP listener, or (Linux-only) an eBPF/XDP ingress
+// that attaches directly to a mirror interface, skipping the TZSP
+// encapsulation hop entirely. Both implementations deliver the same
+// RawPacket shape so the rest of the pipeline (decode, FileWriter,
+// PcapWriter, NetFlow, QingPing) is unchanged regardless of ingress mode.
+package capture
+
+import (
+	"context"
+	"time"
+)
+
+// RawPacket is one captured frame handed to the caller's packet handler,
+// reconstructed from whichever ingress produced it.
+type RawPacket struct {
+	Data      []byte
+	Timestamp time.Time
+	// Ifindex identifies the interface the packet was captured on; zero
+	// for the UDP TZSP listener, which has no single associated NIC.
+	Ifindex int
+	// Direction is "ingress" or "egress"; empty when the ingress mode
+	// can't tell (e.g. UDP TZSP mirrors don't carry that metadata).
+	Direction string
+}
+
+// Handler processes one captured packet.
+type Handler func(pkt RawPacket)
+
+// Capture delivers packets to handler until ctx is cancelled or Close is
+// called.
+type Capture interface {
+	Start(ctx context.Context, handler Handler) error
+	Close() error
+}
@@ -0,0 +1,85 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pavelkim/tzsp_server/internal/tzsp"
+)
+
+// UDPCapture is the default ingress mode: a UDP socket receiving TZSP
+// datagrams from a Mikrotik-style packet mirror. It delivers the
+// decapsulated Ethernet frame, the same bytes server.Server has always
+// worked with.
+type UDPCapture struct {
+	listenAddr string
+	bufferSize int
+	decoder    *tzsp.Decoder
+	conn       *net.UDPConn
+}
+
+// NewUDPCapture creates a UDPCapture bound to listenAddr once Start is
+// called.
+func NewUDPCapture(listenAddr string, bufferSize int) *UDPCapture {
+	return &UDPCapture{
+		listenAddr: listenAddr,
+		bufferSize: bufferSize,
+		decoder:    tzsp.NewDecoder(),
+	}
+}
+
+// Start implements Capture: it opens the UDP socket and blocks, handing
+// each decapsulated packet to handler until ctx is cancelled or Close is
+// called.
+func (c *UDPCapture) Start(ctx context.Context, handler Handler) error {
+	addr, err := net.ResolveUDPAddr("udp", c.listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to resolve address: %w", err)
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on UDP: %w", err)
+	}
+	c.conn = conn
+
+	buf := make([]byte, c.bufferSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			c.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+			n, remoteAddr, err := c.conn.ReadFromUDP(buf)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					continue
+				}
+				continue
+			}
+
+			tzspPkt, err := c.decoder.Decode(buf[:n], remoteAddr.String())
+			if err != nil || len(tzspPkt.EncapPacket) == 0 {
+				continue
+			}
+
+			ts := tzspPkt.ReceivedTime
+			if t := tzspPkt.GetTimestamp(); t != nil {
+				ts = *t
+			}
+
+			handler(RawPacket{Data: tzspPkt.EncapPacket, Timestamp: ts})
+		}
+	}
+}
+
+// Close implements Capture.
+func (c *UDPCapture) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
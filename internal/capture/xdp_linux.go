@@ -0,0 +1,156 @@
+//go:build linux
+
+package capture
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/sys/unix"
+)
+
+// XDPCapture attaches an eBPF/XDP program to iface, as an alternative to
+// TZSP-over-UDP mirroring.
+//
+// The XDP program itself is a minimal, always-XDP_PASS pass-through: it
+// exists to prove out a genuine kernel attachment to iface, not to filter
+// or forward packets on its own. filterExpr is NOT compiled into it —
+// packets still reach the handler unfiltered at the kernel level, and any
+// BPF-style filtering (globalFilter) is applied downstream in the normal
+// pipeline, same as the UDP TZSP ingress. Packet delivery itself happens
+// over a parallel AF_PACKET raw socket bound to iface, since the XDP hook
+// alone has no portable way to hand frames back to userspace without
+// also standing up a ring-buffer/perf-event-array map and a matching
+// receive side in the program.
+type XDPCapture struct {
+	iface      string
+	filterExpr string
+
+	prog *ebpf.Program
+	link link.Link
+	fd   int
+}
+
+// NewXDPCapture checks that iface is set and returns an XDPCapture;
+// filterExpr is stored as given, without validation. It
+// does not attach anything to the interface yet; that happens in Start.
+func NewXDPCapture(iface, filterExpr string) (*XDPCapture, error) {
+	if iface == "" {
+		return nil, fmt.Errorf("capture: xdp mode requires capture.ebpf.interface")
+	}
+	return &XDPCapture{iface: iface, filterExpr: filterExpr, fd: -1}, nil
+}
+
+// Start implements Capture: it loads and attaches an XDP program to the
+// interface, opens a raw socket bound to the same interface to receive
+// the frames the program passed, and delivers each inbound one to
+// handler until ctx is cancelled or Close is called.
+func (c *XDPCapture) Start(ctx context.Context, handler Handler) error {
+	ifi, err := net.InterfaceByName(c.iface)
+	if err != nil {
+		return fmt.Errorf("capture: xdp: failed to resolve interface %q: %w", c.iface, err)
+	}
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:    "tzsp_xdp_pass",
+		Type:    ebpf.XDP,
+		License: "GPL",
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 2), // XDP_PASS
+			asm.Return(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("capture: xdp: failed to load eBPF program: %w", err)
+	}
+	c.prog = prog
+
+	xdpLink, err := link.AttachXDP(link.XDPOptions{
+		Program:   prog,
+		Interface: ifi.Index,
+	})
+	if err != nil {
+		prog.Close()
+		c.prog = nil
+		return fmt.Errorf("capture: xdp: failed to attach to interface %q: %w", c.iface, err)
+	}
+	c.link = xdpLink
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		c.Close()
+		return fmt.Errorf("capture: xdp: failed to open raw socket: %w", err)
+	}
+	c.fd = fd
+
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_ALL),
+		Ifindex:  ifi.Index,
+	}); err != nil {
+		c.Close()
+		return fmt.Errorf("capture: xdp: failed to bind raw socket to %q: %w", c.iface, err)
+	}
+
+	buf := make([]byte, 65536)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &unix.Timeval{Sec: 1})
+
+			n, from, err := unix.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if err == unix.EAGAIN || err == unix.EWOULDBLOCK {
+					continue
+				}
+				continue
+			}
+
+			ll, ok := from.(*unix.SockaddrLinklayer)
+			if !ok || ll.Pkttype == unix.PACKET_OUTGOING {
+				continue
+			}
+
+			data := make([]byte, n)
+			copy(data, buf[:n])
+
+			handler(RawPacket{
+				Data:      data,
+				Timestamp: time.Now(),
+				Ifindex:   ifi.Index,
+				Direction: "ingress",
+			})
+		}
+	}
+}
+
+// Close implements Capture: it detaches the XDP program, releases it,
+// and closes the raw socket.
+func (c *XDPCapture) Close() error {
+	if c.fd >= 0 {
+		unix.Close(c.fd)
+		c.fd = -1
+	}
+	if c.link != nil {
+		c.link.Close()
+		c.link = nil
+	}
+	if c.prog != nil {
+		c.prog.Close()
+		c.prog = nil
+	}
+	return nil
+}
+
+func htons(v uint16) uint16 {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return binary.LittleEndian.Uint16(b)
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package capture
+
+import (
+	"context"
+	"fmt"
+)
+
+// XDPCapture is unavailable on non-Linux platforms: eBPF/XDP is a Linux
+// kernel feature. NewXDPCapture fails at config time rather than at
+// Start, so the operator finds out about the unsupported mode as soon as
+// it's configured.
+type XDPCapture struct{}
+
+// NewXDPCapture always returns an error on non-Linux platforms.
+func NewXDPCapture(iface, filterExpr string) (*XDPCapture, error) {
+	return nil, fmt.Errorf("capture: xdp mode is only supported on linux")
+}
+
+// Start implements Capture. Unreachable since NewXDPCapture always fails.
+func (c *XDPCapture) Start(ctx context.Context, handler Handler) error {
+	return fmt.Errorf("capture: xdp mode is only supported on linux")
+}
+
+// Close implements Capture.
+func (c *XDPCapture) Close() error {
+	return nil
+}
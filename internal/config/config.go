@@ -11,21 +11,177 @@ import (
 type Config struct {
 	Server  ServerConfig  `yaml:"server"`
 	Output  OutputConfig  `yaml:"output"`
+	Parsers ParsersConfig `yaml:"parsers"`
 	Logging LoggingConfig `yaml:"logging"`
 }
 
+// ParsersConfig configures the pluggable L7 protocol parser subsystem
+// (see internal/parsers). Built-in parsers (MQTT, HTTP, raw) are always
+// registered; Plugins lists additional Go plugin shared objects to load
+// at startup.
+type ParsersConfig struct {
+	Plugins []string `yaml:"plugins"`
+}
+
 // ServerConfig contains server-specific settings
 type ServerConfig struct {
-	ListenAddr string `yaml:"listen_addr"`
-	BufferSize int    `yaml:"buffer_size"`
+	ListenAddr string        `yaml:"listen_addr"`
+	BufferSize int           `yaml:"buffer_size"`
+	Capture    CaptureConfig `yaml:"capture"`
+	// MetricsAddr, if non-empty, serves Prometheus metrics (see
+	// internal/metrics) at http://MetricsAddr/metrics for the life of the
+	// process. Empty disables the listener.
+	MetricsAddr string `yaml:"metrics_addr"`
+	// Workers and QueueDepth size the packet pipeline (see
+	// internal/server's pipeline.go): Workers goroutines drain a bounded
+	// queue of depth QueueDepth filled by the UDP read loop. Zero means
+	// a built-in default.
+	Workers    int `yaml:"workers"`
+	QueueDepth int `yaml:"queue_depth"`
+	// OverflowPolicy is "block" (default) or "drop_oldest": what happens
+	// to a new packet when the pipeline queue is full.
+	OverflowPolicy string `yaml:"overflow_policy"`
+	// BPFFilter is a tcpdump-style expression (see internal/filter),
+	// evaluated once per packet before any output sees it, letting an
+	// operator drop uninteresting traffic ahead of every sink's own
+	// bpf_filter instead of inside each one.
+	BPFFilter string `yaml:"bpf_filter"`
+	// DenySourceIPs drops packets whose encapsulated source IP is in this
+	// list, checked alongside BPFFilter.
+	DenySourceIPs []string `yaml:"deny_source_ips"`
+	// SampleRate keeps 1 in every SampleRate packets (after BPFFilter and
+	// DenySourceIPs) and drops the rest, so a mirror of a busy switch can
+	// be thinned out without recompiling anything downstream. 0 or 1
+	// means no sampling.
+	SampleRate int `yaml:"sample_rate"`
+	// ControlSocket, if non-empty, serves the operator control protocol
+	// (see internal/control) on this Unix domain socket path for the life
+	// of the process. Empty disables it. The socket is created 0600, but
+	// its "enable netflow" command is unauthenticated beyond that, so the
+	// target address is additionally checked against
+	// ControlAllowedNetflowAddrs.
+	ControlSocket string `yaml:"control_socket"`
+	// ControlAllowedNetflowAddrs lists the only collector addresses the
+	// control socket's "enable netflow <addr>" command may target; an
+	// empty list rejects the command entirely (opt-in, not opt-out).
+	ControlAllowedNetflowAddrs []string `yaml:"control_allowed_netflow_addrs"`
+}
+
+// CaptureConfig selects the packet ingress mode (see internal/capture).
+// Mode is "udp" (default: the TZSP-over-UDP listener at ListenAddr) or
+// "xdp" (Linux-only eBPF/XDP ingress attached directly to EBPF.Interface,
+// bypassing TZSP encapsulation). In xdp mode only this configuration is
+// checked at startup; the server still receives packets over the UDP
+// listener.
+type CaptureConfig struct {
+	Mode string            `yaml:"mode"`
+	EBPF CaptureEBPFConfig `yaml:"ebpf"`
+}
+
+// CaptureEBPFConfig configures xdp mode.
+type CaptureEBPFConfig struct {
+	Interface string `yaml:"interface"`
+	// Filter is a tcpdump-style BPF expression. It is stored with the XDP
+	// capture but not applied: the XDP program passes every packet.
+	Filter string `yaml:"filter"`
 }
 
 // OutputConfig contains all output mode settings
 type OutputConfig struct {
-	File     FileOutputConfig     `yaml:"file"`
-	PCAP     PCAPOutputConfig     `yaml:"pcap"`
-	NetFlow  NetFlowOutputConfig  `yaml:"netflow"`
-	QingPing QingPingOutputConfig `yaml:"qingping"`
+	File       FileOutputConfig       `yaml:"file"`
+	PCAP       PCAPOutputConfig       `yaml:"pcap"`
+	NetFlow    NetFlowOutputConfig    `yaml:"netflow"`
+	QingPing   QingPingOutputConfig   `yaml:"qingping"`
+	Anomaly    AnomalyOutputConfig    `yaml:"anomaly"`
+	Tracing    TracingOutputConfig    `yaml:"tracing"`
+	Reassembly ReassemblyOutputConfig `yaml:"reassembly"`
+}
+
+// ReassemblyOutputConfig configures the standalone TCP stream
+// reassembly/fingerprinting output (see internal/reassembly): unlike the
+// reassembly manager QingPing uses internally to recover HTTP bodies,
+// this one runs over every TCP flow (not just ones QingPing cares about)
+// and writes its findings to OutputFile.
+type ReassemblyOutputConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// EnableHTTP/EnableTLS toggle the two built-in extractors
+	// independently; both default to disabled so enabling Reassembly
+	// without picking one is a no-op rather than a surprise.
+	EnableHTTP bool `yaml:"enable_http"`
+	EnableTLS  bool `yaml:"enable_tls"`
+	// IdleTimeoutSeconds drops a flow's buffered bytes if no new segment
+	// arrives in this long.
+	IdleTimeoutSeconds int `yaml:"idle_timeout_seconds"`
+	// MaxStreamMemoryBytes bounds how many bytes a single stream may
+	// buffer while waiting for a complete message.
+	MaxStreamMemoryBytes int `yaml:"max_stream_memory_bytes"`
+
+	// OutputFile, MaxSizeMB and MaxBackups configure the rotating
+	// JSON-lines file every HTTPMessage/TLSFingerprint is appended to.
+	OutputFile string `yaml:"output_file"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups"`
+}
+
+// TracingOutputConfig configures the internal/session request/response
+// tracker. SessionIdleTimeout bounds how long a session waits for its
+// response before being expired as timed-out.
+//
+// OTLPEndpoint, if set, exports every session span to that collector
+// (host:port, plaintext gRPC) via session.NewOTLPTracer. Left empty,
+// sessions are traced via session.NewLogTracer instead, which only logs
+// span start/end events.
+type TracingOutputConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	ServiceName        string `yaml:"service_name"`
+	OTLPEndpoint       string `yaml:"otlp_endpoint"`
+	SessionIdleTimeout int    `yaml:"session_idle_timeout_seconds"`
+}
+
+// AnomalyOutputConfig configures the "dropped-only"/anomaly capture
+// subsystem (see internal/anomaly): a list of per-flow triggers evaluated
+// against every decoded packet.
+type AnomalyOutputConfig struct {
+	Enabled  bool                   `yaml:"enabled"`
+	Triggers []AnomalyTriggerConfig `yaml:"triggers"`
+}
+
+// AnomalyTriggerConfig configures one anomaly.TriggerConfig. See that
+// type for how the predicate fields combine.
+type AnomalyTriggerConfig struct {
+	Name string `yaml:"name"`
+
+	Protocol string `yaml:"protocol"`
+	MinPort  uint16 `yaml:"min_port"`
+	MaxPort  uint16 `yaml:"max_port"`
+
+	// TCPFlagsMask/TCPFlagsMatch use the single-letter TCP flag encoding
+	// (S/A/F/R/P/U, e.g. "R" to test for RST); see anomaly.ParseTCPFlags.
+	TCPFlagsMask  string `yaml:"tcp_flags_mask"`
+	TCPFlagsMatch string `yaml:"tcp_flags_match"`
+
+	// PayloadRegex is a Go regexp (RE2) matched against the packet
+	// payload.
+	PayloadRegex string `yaml:"payload_regex"`
+	// PayloadInvalidJSON matches packets with a non-empty payload that
+	// fails to parse as JSON, e.g. to catch malformed QingPing sensor
+	// data in flight.
+	PayloadInvalidJSON bool `yaml:"payload_invalid_json"`
+
+	MinBytes int `yaml:"min_bytes"`
+	MaxBytes int `yaml:"max_bytes"`
+
+	MinFlowAgeSeconds int `yaml:"min_flow_age_seconds"`
+	MaxFlowAgeSeconds int `yaml:"max_flow_age_seconds"`
+
+	MinRetransmits    int `yaml:"min_retransmits"`
+	MaxPacketsPerFlow int `yaml:"max_packets_per_flow"`
+
+	// OutputFile, MaxSizeMB and MaxBackups configure this trigger's
+	// dedicated rotating PCAP-NG capture file.
+	OutputFile string `yaml:"output_file"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups"`
 }
 
 // FileOutputConfig contains file output settings for packet metadata
@@ -33,6 +189,9 @@ type FileOutputConfig struct {
 	Enabled    bool   `yaml:"enabled"`
 	OutputFile string `yaml:"output_file"`
 	Format     string `yaml:"format"`
+	// BPFFilter is a tcpdump-style expression (see internal/filter)
+	// restricting which packets reach this sink. Empty means unfiltered.
+	BPFFilter string `yaml:"bpf_filter"`
 }
 
 // PCAPOutputConfig contains PCAP output settings
@@ -41,6 +200,13 @@ type PCAPOutputConfig struct {
 	OutputFile string `yaml:"output_file"`
 	MaxSizeMB  int    `yaml:"max_size_mb"`
 	MaxBackups int    `yaml:"max_backups"`
+	// Format selects the on-disk capture format: "pcap" (default) or
+	// "pcapng". PCAPNG carries per-packet TZSP tag metadata (RSSI, SNR,
+	// data rate, sensor MAC) that plain PCAP has no place to store.
+	Format string `yaml:"format"`
+	// BPFFilter is a tcpdump-style expression (see internal/filter)
+	// restricting which packets reach this sink. Empty means unfiltered.
+	BPFFilter string `yaml:"bpf_filter"`
 }
 
 // NetFlowOutputConfig contains NetFlow export settings
@@ -50,24 +216,99 @@ type NetFlowOutputConfig struct {
 	Version       int    `yaml:"version"`
 	FlowTimeout   int    `yaml:"flow_timeout"`
 	ActiveTimeout int    `yaml:"active_timeout"`
-}
-
-// QingPingFilterConfig contains packet filtering criteria
-type QingPingFilterConfig struct {
-	SrcIP    string `yaml:"src_ip"`
-	DstIP    string `yaml:"dst_ip"`
-	DstPort  uint16 `yaml:"dst_port"`
-	Protocol string `yaml:"protocol"` // tcp, udp, icmp
+	// MTU bounds how many v9/IPFIX (version 9 or 10) records are batched
+	// into a single export datagram. Unused by v5, which always sends
+	// one record per datagram.
+	MTU int `yaml:"mtu"`
+	// ObservationDomainID identifies this exporter to the collector: the
+	// IPFIX Observation Domain ID, reused as the v9 Source ID. Unused by
+	// v5.
+	ObservationDomainID uint32 `yaml:"observation_domain_id"`
+	// Bidirectional enables biflow accounting: A->B and B->A packets of
+	// one TCP/UDP conversation are tracked on a single flow record
+	// instead of two. On v10 (IPFIX) the two directions are exported as
+	// one record using RFC 5103 reverse Information Elements; v5 and v9
+	// export each direction as its own record.
+	Bidirectional bool `yaml:"bidirectional"`
+	// BPFFilter is a tcpdump-style expression (see internal/filter)
+	// restricting which packets reach this sink. Empty means unfiltered.
+	BPFFilter string `yaml:"bpf_filter"`
 }
 
 // QingPingOutputConfig contains QingPing sensor data export settings
 type QingPingOutputConfig struct {
-	Enabled          bool                 `yaml:"enabled"`
-	Filter           QingPingFilterConfig `yaml:"filter"`
-	StrictJSON       bool                 `yaml:"strict_json"`
-	UpstreamURL      string               `yaml:"upstream_url"`
-	IgnoreSSL        bool                 `yaml:"ignore_ssl"`
-	IgnoreHTTPErrors bool                 `yaml:"ignore_http_errors"`
+	Enabled    bool `yaml:"enabled"`
+	StrictJSON bool `yaml:"strict_json"`
+	// ReassemblyIdleTimeout is how long a buffered TCP stream can sit
+	// without new data before it's dropped, in seconds.
+	ReassemblyIdleTimeout int `yaml:"reassembly_idle_timeout"`
+	// BPFFilter is a tcpdump-style expression (see internal/filter)
+	// restricting which packets reach this sink, replacing the old
+	// src_ip/dst_ip/dst_port/protocol filter fields with one expressive
+	// language, e.g. "vlan 42 and (port 80 or port 443) and not net 10.0.0.0/8".
+	BPFFilter string `yaml:"bpf_filter"`
+	// Sinks lists the fan-out destinations Export publishes decoded
+	// QingPing messages to (replacing the old single UpstreamURL). Each
+	// Export call publishes to every configured sink independently; see
+	// qingping.Sink.
+	Sinks []QingPingSinkConfig `yaml:"sinks"`
+}
+
+// QingPingSinkConfig configures one fan-out destination for the QingPing
+// exporter. Type selects which of the nested blocks below is used.
+type QingPingSinkConfig struct {
+	// Name identifies this sink in logs and GetStats; defaults to Type
+	// if empty.
+	Name string `yaml:"name"`
+	// Type is "http", "mqtt", "file", or "kafka".
+	Type string `yaml:"type"`
+
+	// QueueSize bounds how many pending Publish calls this sink buffers
+	// before Backpressure applies. Zero means a built-in default.
+	QueueSize int `yaml:"queue_size"`
+	// Backpressure is "block" (default) or "drop_oldest": what happens
+	// once the queue is full.
+	Backpressure string `yaml:"backpressure"`
+
+	HTTP  QingPingHTTPSinkConfig  `yaml:"http"`
+	MQTT  QingPingMQTTSinkConfig  `yaml:"mqtt"`
+	File  QingPingFileSinkConfig  `yaml:"file"`
+	Kafka QingPingKafkaSinkConfig `yaml:"kafka"`
+}
+
+// QingPingHTTPSinkConfig configures a Type: "http" sink: POST each
+// message's JSON payload to UpstreamURL.
+type QingPingHTTPSinkConfig struct {
+	UpstreamURL      string `yaml:"upstream_url"`
+	IgnoreSSL        bool   `yaml:"ignore_ssl"`
+	IgnoreHTTPErrors bool   `yaml:"ignore_http_errors"`
+}
+
+// QingPingMQTTSinkConfig configures a Type: "mqtt" sink: re-publish each
+// message to BrokerAddr, remapping its topic via TopicTemplate.
+type QingPingMQTTSinkConfig struct {
+	BrokerAddr string `yaml:"broker_addr"`
+	ClientID   string `yaml:"client_id"`
+	// TopicTemplate's "{topic}" placeholder is replaced with the
+	// message's original MQTT topic; empty re-publishes unchanged.
+	TopicTemplate string `yaml:"topic_template"`
+}
+
+// QingPingFileSinkConfig configures a Type: "file" sink: append each
+// message as one JSON-lines record to OutputFile, with pcap.Writer-style
+// size/backup rotation.
+type QingPingFileSinkConfig struct {
+	OutputFile string `yaml:"output_file"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups"`
+}
+
+// QingPingKafkaSinkConfig configures a Type: "kafka" sink: produce each
+// message to Topic on BrokerAddr.
+type QingPingKafkaSinkConfig struct {
+	BrokerAddr string `yaml:"broker_addr"`
+	Topic      string `yaml:"topic"`
+	ClientID   string `yaml:"client_id"`
 }
 
 // LoggingFileConfig contains file logging settings
@@ -143,6 +384,24 @@ func Load(path string) (*Config, error) {
 	if cfg.Output.NetFlow.ActiveTimeout == 0 {
 		cfg.Output.NetFlow.ActiveTimeout = 120
 	}
+	if cfg.Output.QingPing.ReassemblyIdleTimeout == 0 {
+		cfg.Output.QingPing.ReassemblyIdleTimeout = 30
+	}
+	if cfg.Output.PCAP.Format == "" {
+		cfg.Output.PCAP.Format = "pcap"
+	}
+	if cfg.Output.NetFlow.MTU == 0 {
+		cfg.Output.NetFlow.MTU = 1400
+	}
+	if cfg.Output.Tracing.SessionIdleTimeout == 0 {
+		cfg.Output.Tracing.SessionIdleTimeout = 30
+	}
+	if cfg.Server.Capture.Mode == "" {
+		cfg.Server.Capture.Mode = "udp"
+	}
+	if cfg.Output.Reassembly.IdleTimeoutSeconds == 0 {
+		cfg.Output.Reassembly.IdleTimeoutSeconds = 30
+	}
 
 	return cfg, nil
 }
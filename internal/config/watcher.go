@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce coalesces bursts of filesystem events (many editors write
+// a file in several steps) into a single reload.
+const reloadDebounce = 500 * time.Millisecond
+
+// Watcher reloads a Config from disk whenever the file changes or the
+// process receives SIGHUP, and publishes validated reloads on Changes().
+// It never touches the config currently in use until a new one has been
+// loaded and validated successfully.
+type Watcher struct {
+	path string
+
+	mu      sync.RWMutex
+	current *Config
+
+	fsWatcher *fsnotify.Watcher
+	sigChan   chan os.Signal
+	changes   chan *Config
+	errors    chan error
+	done      chan struct{}
+}
+
+// NewWatcher loads the config at path and starts watching it for changes
+// on disk (via fsnotify) and on SIGHUP.
+func NewWatcher(path string) (*Watcher, error) {
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	// Watch the containing directory rather than the file itself: many
+	// editors and config management tools replace the file via
+	// rename/create rather than writing in place, which a watch on the
+	// file alone would miss.
+	dir := filepath.Dir(path)
+	if err := fsWatcher.Add(dir); err != nil {
+		fsWatcher.Close()
+		return nil, fmt.Errorf("failed to watch config directory %q: %w", dir, err)
+	}
+
+	w := &Watcher{
+		path:      path,
+		current:   cfg,
+		fsWatcher: fsWatcher,
+		sigChan:   make(chan os.Signal, 1),
+		changes:   make(chan *Config, 1),
+		errors:    make(chan error, 1),
+		done:      make(chan struct{}),
+	}
+
+	signal.Notify(w.sigChan, syscall.SIGHUP)
+
+	go w.run()
+
+	return w, nil
+}
+
+// Current returns the most recently loaded config.
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Changes returns a channel of successfully reloaded configs.
+func (w *Watcher) Changes() <-chan *Config {
+	return w.changes
+}
+
+// Errors returns a channel of reload failures (e.g. YAML syntax errors);
+// the previously loaded config remains current when a reload fails.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Close stops watching and releases the underlying fsnotify watcher.
+func (w *Watcher) Close() error {
+	close(w.done)
+	signal.Stop(w.sigChan)
+	return w.fsWatcher.Close()
+}
+
+func (w *Watcher) run() {
+	var debounce *time.Timer
+
+	reload := func() {
+		cfg, err := Load(w.path)
+		if err != nil {
+			select {
+			case w.errors <- fmt.Errorf("config reload failed, keeping previous config: %w", err):
+			default:
+			}
+			return
+		}
+
+		w.mu.Lock()
+		w.current = cfg
+		w.mu.Unlock()
+
+		select {
+		case w.changes <- cfg:
+		default:
+			// Drop if nobody's draining fast enough; Current() always
+			// reflects the latest load regardless.
+		}
+	}
+
+	for {
+		select {
+		case <-w.done:
+			if debounce != nil {
+				debounce.Stop()
+			}
+			return
+
+		case <-w.sigChan:
+			reload()
+
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(w.path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+
+			if debounce == nil {
+				debounce = time.AfterFunc(reloadDebounce, reload)
+			} else {
+				debounce.Reset(reloadDebounce)
+			}
+
+		case _, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
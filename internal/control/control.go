@@ -0,0 +1,169 @@
+// Package control implements a tiny line-oriented protocol, served over a
+// Unix domain socket, for adjusting a running server without restarting
+// it: reload, set filter <bpf>, rotate pcap, enable netflow <addr>,
+// disable qingping, stats. It complements SIGHUP/file-watch config
+// reload (see internal/config.Watcher) with commands narrower than a
+// full config reload.
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pavelkim/tzsp_server/internal/logger"
+)
+
+// Commands are the handlers a Server dispatches each line to. A nil
+// handler makes its command reply with an error instead of panicking, so
+// callers can wire up only the subset they need.
+type Commands struct {
+	Reload          func() error
+	SetFilter       func(expr string) error
+	RotatePCAP      func() error
+	EnableNetflow   func(addr string) error
+	DisableQingPing func() error
+	Stats           func() string
+}
+
+// Server accepts connections on a Unix domain socket and dispatches each
+// newline-terminated command to Commands, writing back "OK" or
+// "ERR <message>".
+type Server struct {
+	listener net.Listener
+	cmds     Commands
+	logger   *logger.Logger
+	wg       sync.WaitGroup
+}
+
+// Serve starts listening on socketPath in the background. Callers should
+// defer Close to shut it down. Any file left over at socketPath by a
+// previous, uncleanly-stopped run is removed first.
+func Serve(socketPath string, cmds Commands, log *logger.Logger) (*Server, error) {
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	// The control protocol has no authentication of its own, so access is
+	// gated entirely by filesystem permissions: only the user running the
+	// process (and root) may connect.
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to set control socket permissions: %w", err)
+	}
+
+	s := &Server{listener: ln, cmds: cmds, logger: log}
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if _, err := fmt.Fprintln(conn, s.dispatch(line)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) dispatch(line string) string {
+	fields := strings.Fields(line)
+	cmd := fields[0]
+	args := fields[1:]
+
+	switch cmd {
+	case "reload":
+		return result(call(s.cmds.Reload))
+
+	case "stats":
+		if s.cmds.Stats == nil {
+			return "ERR stats not available"
+		}
+		return s.cmds.Stats()
+
+	case "set":
+		if len(args) < 2 || args[0] != "filter" {
+			return "ERR usage: set filter <bpf expression>"
+		}
+		if s.cmds.SetFilter == nil {
+			return "ERR set filter not available"
+		}
+		return result(s.cmds.SetFilter(strings.Join(args[1:], " ")))
+
+	case "rotate":
+		if len(args) != 1 || args[0] != "pcap" {
+			return "ERR usage: rotate pcap"
+		}
+		return result(call(s.cmds.RotatePCAP))
+
+	case "enable":
+		if len(args) != 2 || args[0] != "netflow" {
+			return "ERR usage: enable netflow <addr>"
+		}
+		if s.cmds.EnableNetflow == nil {
+			return "ERR enable netflow not available"
+		}
+		return result(s.cmds.EnableNetflow(args[1]))
+
+	case "disable":
+		if len(args) != 1 || args[0] != "qingping" {
+			return "ERR usage: disable qingping"
+		}
+		return result(call(s.cmds.DisableQingPing))
+
+	default:
+		return fmt.Sprintf("ERR unknown command %q", cmd)
+	}
+}
+
+// call invokes fn, tolerating a nil handler for a command the caller
+// chose not to wire up.
+func call(fn func() error) error {
+	if fn == nil {
+		return fmt.Errorf("command not available")
+	}
+	return fn()
+}
+
+func result(err error) string {
+	if err != nil {
+		return "ERR " + err.Error()
+	}
+	return "OK"
+}
+
+// Close stops accepting new connections and waits for in-flight ones to
+// finish.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
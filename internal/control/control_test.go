@@ -0,0 +1,207 @@
+package control
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name string
+		cmds Commands
+		line string
+		want string
+	}{
+		{
+			name: "reload ok",
+			cmds: Commands{Reload: func() error { return nil }},
+			line: "reload",
+			want: "OK",
+		},
+		{
+			name: "reload error",
+			cmds: Commands{Reload: func() error { return errors.New("bad config") }},
+			line: "reload",
+			want: "ERR bad config",
+		},
+		{
+			name: "reload not wired up",
+			cmds: Commands{},
+			line: "reload",
+			want: "ERR command not available",
+		},
+		{
+			name: "stats",
+			cmds: Commands{Stats: func() string { return "packets=42" }},
+			line: "stats",
+			want: "packets=42",
+		},
+		{
+			name: "stats not available",
+			cmds: Commands{},
+			line: "stats",
+			want: "ERR stats not available",
+		},
+		{
+			name: "set filter ok",
+			cmds: Commands{SetFilter: func(expr string) error {
+				if expr != "host 10.0.0.1 and port 443" {
+					return fmt.Errorf("unexpected expr %q", expr)
+				}
+				return nil
+			}},
+			line: "set filter host 10.0.0.1 and port 443",
+			want: "OK",
+		},
+		{
+			name: "set filter bad usage",
+			cmds: Commands{},
+			line: "set bogus",
+			want: "ERR usage: set filter <bpf expression>",
+		},
+		{
+			name: "set filter not available",
+			cmds: Commands{},
+			line: "set filter host 10.0.0.1",
+			want: "ERR set filter not available",
+		},
+		{
+			name: "rotate pcap ok",
+			cmds: Commands{RotatePCAP: func() error { return nil }},
+			line: "rotate pcap",
+			want: "OK",
+		},
+		{
+			name: "rotate bad usage",
+			cmds: Commands{},
+			line: "rotate",
+			want: "ERR usage: rotate pcap",
+		},
+		{
+			name: "enable netflow ok",
+			cmds: Commands{EnableNetflow: func(addr string) error {
+				if addr != "127.0.0.1:2055" {
+					return fmt.Errorf("unexpected addr %q", addr)
+				}
+				return nil
+			}},
+			line: "enable netflow 127.0.0.1:2055",
+			want: "OK",
+		},
+		{
+			name: "enable netflow bad usage",
+			cmds: Commands{},
+			line: "enable netflow",
+			want: "ERR usage: enable netflow <addr>",
+		},
+		{
+			name: "enable netflow not available",
+			cmds: Commands{},
+			line: "enable netflow 127.0.0.1:2055",
+			want: "ERR enable netflow not available",
+		},
+		{
+			name: "disable qingping ok",
+			cmds: Commands{DisableQingPing: func() error { return nil }},
+			line: "disable qingping",
+			want: "OK",
+		},
+		{
+			name: "disable qingping bad usage",
+			cmds: Commands{},
+			line: "disable foo",
+			want: "ERR usage: disable qingping",
+		},
+		{
+			name: "unknown command",
+			cmds: Commands{},
+			line: "frobnicate",
+			want: `ERR unknown command "frobnicate"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{cmds: tt.cmds}
+			if got := s.dispatch(tt.line); got != tt.want {
+				t.Fatalf("dispatch(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeDispatchesOverSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+
+	reloaded := false
+	cmds := Commands{
+		Reload: func() error { reloaded = true; return nil },
+		Stats:  func() string { return "packets=1" },
+	}
+
+	srv, err := Serve(socketPath, cmds, nil)
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	defer srv.Close()
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	fmt.Fprintln(conn, "reload")
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("ReadString: %v", err)
+	}
+	if line != "OK\n" {
+		t.Fatalf("reload reply: got %q, want %q", line, "OK\n")
+	}
+	if !reloaded {
+		t.Fatalf("expected Reload handler to have been invoked")
+	}
+
+	fmt.Fprintln(conn, "stats")
+	line, err = reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("ReadString: %v", err)
+	}
+	if line != "packets=1\n" {
+		t.Fatalf("stats reply: got %q, want %q", line, "packets=1\n")
+	}
+}
+
+func TestServeIgnoresBlankLines(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+
+	srv, err := Serve(socketPath, Commands{Stats: func() string { return "ok" }}, nil)
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	defer srv.Close()
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "\n   \nstats\n")
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("ReadString: %v", err)
+	}
+	if line != "ok\n" {
+		t.Fatalf("got %q, want %q (blank lines should be skipped)", line, "ok\n")
+	}
+}
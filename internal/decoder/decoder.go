@@ -20,7 +20,29 @@ type PacketInfo struct {
 	Length     int
 	PayloadLen int
 	TCPFlags   string
+	// ToS is the IPv4 type-of-service byte, or the IPv6 traffic class,
+	// carried through to flow exporters that key on it.
+	ToS uint8
+	// Payload is the TCP/UDP application-layer payload, e.g. the MQTT
+	// stream bytes for QingPing export. Empty for non-IP or headers-only
+	// packets.
+	Payload    []byte
 	PacketData []byte
+	// TCPSeq is the TCP sequence number, used by internal/anomaly to spot
+	// retransmissions (a later segment that doesn't advance the
+	// sequence number). Zero for non-TCP packets.
+	TCPSeq uint32
+	// EtherType is the outermost Ethernet frame's EtherType (e.g. 0x0800
+	// for IPv4, 0x86DD for IPv6, 0x8100 for a VLAN tag, 0x8847 for
+	// MPLS-unicast), used for the per-EtherType stats breakdown.
+	EtherType uint16
+	// HasVLAN/VLANID carry the 802.1Q tag, if present, unwrapped before
+	// reaching the L3 layer.
+	HasVLAN bool
+	VLANID  uint16
+	// HasMPLS/MPLSLabel carry the outermost MPLS label, if present.
+	HasMPLS   bool
+	MPLSLabel uint32
 }
 
 // Decoder decodes encapsulated network packets
@@ -47,6 +69,25 @@ func (d *Decoder) Decode(data []byte, timestamp int64) (*PacketInfo, error) {
 		eth, _ := ethLayer.(*layers.Ethernet)
 		info.SrcMAC = eth.SrcMAC.String()
 		info.DstMAC = eth.DstMAC.String()
+		info.EtherType = uint16(eth.EthernetType)
+	}
+
+	// Unwrap a VLAN (802.1Q) tag, if gopacket found one between the
+	// Ethernet and L3 layers.
+	if vlanLayer := packet.Layer(layers.LayerTypeDot1Q); vlanLayer != nil {
+		vlan, _ := vlanLayer.(*layers.Dot1Q)
+		info.HasVLAN = true
+		info.VLANID = vlan.VLANIdentifier
+	}
+
+	// Unwrap the outermost MPLS label, if present. Deeper labels in a
+	// stack aren't tracked individually; only the first one gopacket
+	// exposes matters for routing this packet to the right L4 handling
+	// below, once the payload beneath the stack is itself IP.
+	if mplsLayer := packet.Layer(layers.LayerTypeMPLS); mplsLayer != nil {
+		mpls, _ := mplsLayer.(*layers.MPLS)
+		info.HasMPLS = true
+		info.MPLSLabel = mpls.Label
 	}
 
 	// Extract IPv4 layer
@@ -55,6 +96,7 @@ func (d *Decoder) Decode(data []byte, timestamp int64) (*PacketInfo, error) {
 		info.SrcIP = ip.SrcIP.String()
 		info.DstIP = ip.DstIP.String()
 		info.Protocol = ip.Protocol.String()
+		info.ToS = ip.TOS
 	}
 
 	// Extract IPv6 layer
@@ -63,6 +105,7 @@ func (d *Decoder) Decode(data []byte, timestamp int64) (*PacketInfo, error) {
 		info.SrcIP = ip.SrcIP.String()
 		info.DstIP = ip.DstIP.String()
 		info.Protocol = ip.NextHeader.String()
+		info.ToS = ip.TrafficClass
 	}
 
 	// Extract TCP layer
@@ -72,9 +115,11 @@ func (d *Decoder) Decode(data []byte, timestamp int64) (*PacketInfo, error) {
 		info.DstPort = uint16(tcp.DstPort)
 		info.Protocol = "TCP"
 		info.TCPFlags = d.formatTCPFlags(tcp)
+		info.TCPSeq = tcp.Seq
 
 		if appLayer := packet.ApplicationLayer(); appLayer != nil {
 			info.PayloadLen = len(appLayer.Payload())
+			info.Payload = appLayer.Payload()
 		}
 	}
 
@@ -87,6 +132,7 @@ func (d *Decoder) Decode(data []byte, timestamp int64) (*PacketInfo, error) {
 
 		if appLayer := packet.ApplicationLayer(); appLayer != nil {
 			info.PayloadLen = len(appLayer.Payload())
+			info.Payload = appLayer.Payload()
 		}
 	}
 
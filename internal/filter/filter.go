@@ -0,0 +1,399 @@
+// Package filter implements a small tcpdump-inspired filter expression
+// language for deciding whether a decoded packet should reach a given
+// output sink.
+//
+// The obvious way to get this is gopacket/pcap.CompileBPFFilter, but that
+// package is a cgo binding to libpcap, and gopacket/pcap.NewBPF has the
+// same dependency. Neither is an option for a lightweight UDP daemon that
+// otherwise has zero cgo dependencies, so this package instead parses a
+// deliberately small subset of tcpdump syntax directly into an AST that's
+// evaluated against each packet. It covers the predicates operators
+// actually need (host/net/port/vlan/proto, and/or/not, parens) rather
+// than the full BPF grammar.
+package filter
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Filter matches raw encapsulated packets (tzsp.Packet.EncapPacket)
+// against a compiled expression.
+type Filter struct {
+	expr string
+	root node
+}
+
+// Compile parses expr into a Filter. An empty expr is invalid; callers
+// that want "no filter configured" should simply not call Compile and
+// leave the *Filter nil, since a nil *Filter matches everything via
+// Matches.
+func Compile(expr string) (*Filter, error) {
+	tokens := tokenize(expr)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("bpf_filter %q: %w", expr, err)
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("bpf_filter %q: unexpected token %q", expr, p.tokens[p.pos])
+	}
+
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// String returns the original filter expression.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
+
+// Matches reports whether data matches the filter. A nil Filter (no
+// bpf_filter configured) matches everything, so callers can apply it
+// unconditionally.
+func (f *Filter) Matches(data []byte) bool {
+	if f == nil {
+		return true
+	}
+	return f.root.eval(buildContext(data))
+}
+
+// direction narrows a host/net/port predicate to the packet's source or
+// destination only; dirAny matches either.
+type direction int
+
+const (
+	dirAny direction = iota
+	dirSrc
+	dirDst
+)
+
+// matchContext holds the fields a compiled expression can test, decoded
+// once per Matches call.
+type matchContext struct {
+	vlanIDs  []int
+	srcIP    net.IP
+	dstIP    net.IP
+	srcPort  uint16
+	dstPort  uint16
+	protocol string // "tcp", "udp", "icmp", or "" if none of those
+}
+
+func buildContext(data []byte) *matchContext {
+	ctx := &matchContext{}
+
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	for _, l := range packet.Layers() {
+		if dot1q, ok := l.(*layers.Dot1Q); ok {
+			ctx.vlanIDs = append(ctx.vlanIDs, int(dot1q.VLANIdentifier))
+		}
+	}
+
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv4)
+		ctx.srcIP = ip.SrcIP
+		ctx.dstIP = ip.DstIP
+	}
+	if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv6)
+		ctx.srcIP = ip.SrcIP
+		ctx.dstIP = ip.DstIP
+	}
+
+	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
+		tcp := tcpLayer.(*layers.TCP)
+		ctx.srcPort = uint16(tcp.SrcPort)
+		ctx.dstPort = uint16(tcp.DstPort)
+		ctx.protocol = "tcp"
+	}
+	if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
+		udp := udpLayer.(*layers.UDP)
+		ctx.srcPort = uint16(udp.SrcPort)
+		ctx.dstPort = uint16(udp.DstPort)
+		ctx.protocol = "udp"
+	}
+	if packet.Layer(layers.LayerTypeICMPv4) != nil || packet.Layer(layers.LayerTypeICMPv6) != nil {
+		ctx.protocol = "icmp"
+	}
+
+	return ctx
+}
+
+// node is one term of a compiled filter expression.
+type node interface {
+	eval(ctx *matchContext) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n *andNode) eval(ctx *matchContext) bool { return n.left.eval(ctx) && n.right.eval(ctx) }
+
+type orNode struct{ left, right node }
+
+func (n *orNode) eval(ctx *matchContext) bool { return n.left.eval(ctx) || n.right.eval(ctx) }
+
+type notNode struct{ child node }
+
+func (n *notNode) eval(ctx *matchContext) bool { return !n.child.eval(ctx) }
+
+type vlanNode struct{ id int }
+
+func (n *vlanNode) eval(ctx *matchContext) bool {
+	for _, id := range ctx.vlanIDs {
+		if id == n.id {
+			return true
+		}
+	}
+	return false
+}
+
+type hostNode struct {
+	dir direction
+	ip  net.IP
+}
+
+func (n *hostNode) eval(ctx *matchContext) bool {
+	if n.dir != dirDst && ctx.srcIP != nil && ctx.srcIP.Equal(n.ip) {
+		return true
+	}
+	if n.dir != dirSrc && ctx.dstIP != nil && ctx.dstIP.Equal(n.ip) {
+		return true
+	}
+	return false
+}
+
+type netNode struct {
+	dir   direction
+	ipnet *net.IPNet
+}
+
+func (n *netNode) eval(ctx *matchContext) bool {
+	if n.dir != dirDst && ctx.srcIP != nil && n.ipnet.Contains(ctx.srcIP) {
+		return true
+	}
+	if n.dir != dirSrc && ctx.dstIP != nil && n.ipnet.Contains(ctx.dstIP) {
+		return true
+	}
+	return false
+}
+
+type portNode struct {
+	dir  direction
+	port uint16
+}
+
+func (n *portNode) eval(ctx *matchContext) bool {
+	if n.dir != dirDst && ctx.srcPort == n.port {
+		return true
+	}
+	if n.dir != dirSrc && ctx.dstPort == n.port {
+		return true
+	}
+	return false
+}
+
+type protoNode struct{ proto string }
+
+func (n *protoNode) eval(ctx *matchContext) bool { return ctx.protocol == n.proto }
+
+// tokenize splits expr into words, treating "(" and ")" as standalone
+// tokens even when not surrounded by whitespace.
+func tokenize(expr string) []string {
+	var tokens []string
+	var cur strings.Builder
+
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for _, r := range expr {
+		switch {
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// parser is a simple recursive-descent parser over tokenize's output.
+// Precedence, loosest to tightest: or, and, not, primary.
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "or", "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "and", "&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if isKeyword(p.peek(), "not", "!") {
+		p.next()
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.peek()
+	if tok == "" {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	if tok == "(" {
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("expected ')'")
+		}
+		p.next()
+		return n, nil
+	}
+
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (node, error) {
+	dir := dirAny
+	tok := strings.ToLower(p.next())
+
+	if tok == "src" || tok == "dst" {
+		if tok == "src" {
+			dir = dirSrc
+		} else {
+			dir = dirDst
+		}
+		tok = strings.ToLower(p.next())
+	}
+
+	switch tok {
+	case "host":
+		ipStr := p.next()
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", ipStr)
+		}
+		return &hostNode{dir: dir, ip: ip}, nil
+
+	case "net":
+		cidr := p.next()
+		if !strings.Contains(cidr, "/") {
+			cidr += "/32"
+			if strings.Contains(cidr, ":") {
+				cidr = strings.TrimSuffix(cidr, "/32") + "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		return &netNode{dir: dir, ipnet: ipnet}, nil
+
+	case "port":
+		portStr := p.next()
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %w", portStr, err)
+		}
+		return &portNode{dir: dir, port: uint16(port)}, nil
+
+	case "vlan":
+		idStr := p.next()
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vlan id %q: %w", idStr, err)
+		}
+		return &vlanNode{id: id}, nil
+
+	case "tcp", "udp", "icmp":
+		if dir != dirAny {
+			return nil, fmt.Errorf("%q cannot be qualified with src/dst", tok)
+		}
+		return &protoNode{proto: tok}, nil
+
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+
+	default:
+		return nil, fmt.Errorf("unknown filter term %q", tok)
+	}
+}
+
+func isKeyword(tok string, keywords ...string) bool {
+	tok = strings.ToLower(tok)
+	for _, k := range keywords {
+		if tok == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package filter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// buildTCPPacket assembles a minimal Ethernet/IPv4/TCP frame, optionally
+// tagged with a single 802.1Q VLAN, for exercising Filter.Matches.
+func buildTCPPacket(t *testing.T, vlanID int, srcIP, dstIP string, srcPort, dstPort uint16) []byte {
+	t.Helper()
+
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{6, 7, 8, 9, 10, 11},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		IHL:      5,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.ParseIP(srcIP),
+		DstIP:    net.ParseIP(dstIP),
+	}
+	tcp := &layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: layers.TCPPort(dstPort),
+		SYN:     true,
+	}
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+
+	var layersToSerialize []gopacket.SerializableLayer
+	if vlanID > 0 {
+		eth.EthernetType = layers.EthernetTypeDot1Q
+		dot1q := &layers.Dot1Q{VLANIdentifier: uint16(vlanID), Type: layers.EthernetTypeIPv4}
+		layersToSerialize = []gopacket.SerializableLayer{eth, dot1q, ip, tcp}
+	} else {
+		layersToSerialize = []gopacket.SerializableLayer{eth, ip, tcp}
+	}
+
+	if err := gopacket.SerializeLayers(buf, opts, layersToSerialize...); err != nil {
+		t.Fatalf("SerializeLayers: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// buildUDPPacket assembles a minimal Ethernet/IPv4/UDP frame.
+func buildUDPPacket(t *testing.T, srcIP, dstIP string, srcPort, dstPort uint16) []byte {
+	t.Helper()
+
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{6, 7, 8, 9, 10, 11},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		IHL:      5,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP(srcIP),
+		DstIP:    net.ParseIP(dstIP),
+	}
+	udp := &layers.UDP{
+		SrcPort: layers.UDPPort(srcPort),
+		DstPort: layers.UDPPort(dstPort),
+	}
+	udp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, udp); err != nil {
+		t.Fatalf("SerializeLayers: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty", ""},
+		{"whitespace only", "   "},
+		{"unknown term", "bogus 1.2.3.4"},
+		{"bad host", "host not-an-ip"},
+		{"bad cidr", "net 10.0.0.0/abc"},
+		{"bad port", "port notanumber"},
+		{"bad vlan", "vlan notanumber"},
+		{"proto with dir", "src tcp"},
+		{"unclosed paren", "(host 10.0.0.1"},
+		{"trailing token", "host 10.0.0.1 host 10.0.0.2"},
+		{"dangling and", "host 10.0.0.1 and"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile(tt.expr); err == nil {
+				t.Fatalf("Compile(%q): expected error, got nil", tt.expr)
+			}
+		})
+	}
+}
+
+func TestMatchesNilFilterMatchesEverything(t *testing.T) {
+	var f *Filter
+	pkt := buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443)
+	if !f.Matches(pkt) {
+		t.Fatalf("nil Filter should match everything")
+	}
+}
+
+func TestMatchesPredicates(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		pkt  []byte
+		want bool
+	}{
+		{
+			name: "host matches src or dst",
+			expr: "host 10.0.0.2",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "src host doesn't match dst",
+			expr: "src host 10.0.0.2",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: false,
+		},
+		{
+			name: "dst host matches dst",
+			expr: "dst host 10.0.0.2",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "net matches containing CIDR",
+			expr: "net 10.0.0.0/24",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "192.168.1.1", 1234, 443),
+			want: true,
+		},
+		{
+			name: "net doesn't match outside CIDR",
+			expr: "net 10.0.1.0/24",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "192.168.1.1", 1234, 443),
+			want: false,
+		},
+		{
+			name: "port matches src or dst",
+			expr: "port 443",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "dst port doesn't match src port",
+			expr: "dst port 1234",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: false,
+		},
+		{
+			name: "vlan matches tagged packet",
+			expr: "vlan 100",
+			pkt:  buildTCPPacket(t, 100, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "vlan doesn't match untagged packet",
+			expr: "vlan 100",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: false,
+		},
+		{
+			name: "tcp matches TCP packet",
+			expr: "tcp",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "tcp doesn't match UDP packet",
+			expr: "tcp",
+			pkt:  buildUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53),
+			want: false,
+		},
+		{
+			name: "udp matches UDP packet",
+			expr: "udp",
+			pkt:  buildUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53),
+			want: true,
+		},
+		{
+			name: "and combines two true predicates",
+			expr: "host 10.0.0.1 and port 443",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "and fails if one predicate is false",
+			expr: "host 10.0.0.1 and port 80",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: false,
+		},
+		{
+			name: "or matches if either predicate is true",
+			expr: "port 80 or port 443",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "not negates the predicate",
+			expr: "not port 443",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: false,
+		},
+		{
+			name: "parens group or inside and",
+			expr: "host 10.0.0.1 and (port 80 or port 443)",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "symbolic operators (&&, ||, !)",
+			expr: "! port 80 && (host 10.0.0.1 || host 10.0.0.9)",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "case-insensitive keywords",
+			expr: "HOST 10.0.0.1 AND PORT 443",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+		{
+			name: "bare net CIDR shorthand defaults to /32",
+			expr: "net 10.0.0.1",
+			pkt:  buildTCPPacket(t, 0, "10.0.0.1", "10.0.0.2", 1234, 443),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.expr, err)
+			}
+			if got := f.Matches(tt.pkt); got != tt.want {
+				t.Fatalf("Matches(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringReturnsOriginalExpression(t *testing.T) {
+	const expr = "host 10.0.0.1 and port 443"
+	f, err := Compile(expr)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if f.String() != expr {
+		t.Fatalf("String(): got %q, want %q", f.String(), expr)
+	}
+
+	var nilFilter *Filter
+	if nilFilter.String() != "" {
+		t.Fatalf("nil Filter.String(): got %q, want empty", nilFilter.String())
+	}
+}
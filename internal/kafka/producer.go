@@ -0,0 +1,111 @@
+// Package kafka implements a minimal fire-and-forget Kafka producer: just
+// enough of the wire protocol to ship a message to a topic/partition with
+// acks=0. It intentionally does not implement metadata discovery, broker
+// routing, retries, or compression; it opens one TCP connection to a
+// single broker address and writes directly to partition 0.
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"time"
+)
+
+const (
+	apiKeyProduce  int16 = 0
+	apiVersion     int16 = 0
+	requiredAcks0  int16 = 0 // fire-and-forget: broker sends no response
+	produceTimeout int32 = 5000
+)
+
+const dialTimeout = 5 * time.Second
+
+// Producer sends messages to a single Kafka topic/partition over one TCP
+// connection, with acks=0 (no broker acknowledgement, and therefore no
+// delivery guarantee beyond "the bytes reached the OS socket buffer").
+type Producer struct {
+	conn          net.Conn
+	clientID      string
+	correlationID int32
+}
+
+// Dial connects to a Kafka broker at addr ("host:port").
+func Dial(addr, clientID string) (*Producer, error) {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Kafka broker %s: %w", addr, err)
+	}
+	return &Producer{conn: conn, clientID: clientID}, nil
+}
+
+// Produce sends value to topic's partition 0 with a null key. Because
+// this Producer always uses acks=0, a successful return only means the
+// request was written to the connection, not that the broker durably
+// stored it.
+func (p *Producer) Produce(topic string, value []byte) error {
+	message := encodeMessage(value)
+
+	messageSet := new(bytes.Buffer)
+	binary.Write(messageSet, binary.BigEndian, int64(0)) // offset, ignored by the broker on produce
+	binary.Write(messageSet, binary.BigEndian, int32(len(message)))
+	messageSet.Write(message)
+
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.BigEndian, requiredAcks0)
+	binary.Write(body, binary.BigEndian, produceTimeout)
+	binary.Write(body, binary.BigEndian, int32(1)) // one topic
+	writeKafkaString(body, topic)
+	binary.Write(body, binary.BigEndian, int32(1)) // one partition
+	binary.Write(body, binary.BigEndian, int32(0)) // partition 0
+	binary.Write(body, binary.BigEndian, int32(messageSet.Len()))
+	body.Write(messageSet.Bytes())
+
+	p.correlationID++
+	header := new(bytes.Buffer)
+	binary.Write(header, binary.BigEndian, apiKeyProduce)
+	binary.Write(header, binary.BigEndian, apiVersion)
+	binary.Write(header, binary.BigEndian, p.correlationID)
+	writeKafkaString(header, p.clientID)
+
+	request := new(bytes.Buffer)
+	binary.Write(request, binary.BigEndian, int32(header.Len()+body.Len()))
+	request.Write(header.Bytes())
+	request.Write(body.Bytes())
+
+	_, err := p.conn.Write(request.Bytes())
+	return err
+}
+
+// Close closes the underlying TCP connection.
+func (p *Producer) Close() error {
+	return p.conn.Close()
+}
+
+// encodeMessage builds a v0 (uncompressed) Kafka Message: Crc, MagicByte,
+// Attributes, then null Key and Value, with Crc32 (IEEE) computed over
+// everything after it.
+func encodeMessage(value []byte) []byte {
+	body := new(bytes.Buffer)
+	body.WriteByte(0)                               // magic byte: message format v0
+	body.WriteByte(0)                               // attributes: no compression
+	binary.Write(body, binary.BigEndian, int32(-1)) // key length: null key
+	binary.Write(body, binary.BigEndian, int32(len(value)))
+	body.Write(value)
+
+	crc := crc32.ChecksumIEEE(body.Bytes())
+
+	out := new(bytes.Buffer)
+	binary.Write(out, binary.BigEndian, crc)
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+// writeKafkaString appends a Kafka protocol string (2-byte big-endian
+// length prefix, then the bytes) to buf.
+func writeKafkaString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, int16(len(s)))
+	buf.WriteString(s)
+}
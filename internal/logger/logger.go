@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"fmt"
 	"os"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
@@ -12,6 +14,8 @@ type Logger struct {
 	consoleLogger  *logrus.Logger
 	fileEnabled    bool
 	consoleEnabled bool
+	fileConfig     FileConfig
+	fileMu         sync.Mutex
 }
 
 // FileConfig contains file logger configuration
@@ -76,6 +80,7 @@ func NewLogger(cfg *Config) (*Logger, error) {
 
 		l.fileLogger = fileLog
 		l.fileEnabled = true
+		l.fileConfig = cfg.File
 	}
 
 	// Setup console logger if enabled
@@ -219,6 +224,31 @@ func (l *Logger) Debug(msg string, fields ...interface{}) {
 	}
 }
 
+// Reopen closes and reopens the file log handle at the same path. This
+// lets log rotation tools that move/truncate the file out from under us
+// (anything other than logrotate's copytruncate mode) be used safely:
+// call Reopen after the rotation, typically from a SIGHUP handler.
+func (l *Logger) Reopen() error {
+	if !l.fileEnabled {
+		return nil
+	}
+
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+
+	if closer, ok := l.fileLogger.Out.(*os.File); ok {
+		closer.Close()
+	}
+
+	logFile, err := os.OpenFile(l.fileConfig.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen log file %q: %w", l.fileConfig.Path, err)
+	}
+	l.fileLogger.SetOutput(logFile)
+
+	return nil
+}
+
 // parseFields converts variadic arguments to logrus.Fields
 func (l *Logger) parseFields(fields ...interface{}) logrus.Fields {
 	result := make(logrus.Fields)
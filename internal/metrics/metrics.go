@@ -0,0 +1,134 @@
+// Package metrics collects Prometheus metrics for the TZSP server and
+// exposes them over an embedded HTTP listener, so operators can track
+// long-term trends via a scraper instead of grepping the periodic text
+// stats report for them.
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds every collector the server reports. All counters/gauges
+// are safe for concurrent use, as guaranteed by client_golang.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	PacketsReceived *prometheus.CounterVec // by source_ip
+	BytesReceived   *prometheus.CounterVec // by source_ip
+	PacketsByProto  *prometheus.CounterVec // by protocol (tcp/udp/icmp/other)
+	DecodeErrors    *prometheus.CounterVec // by reason
+
+	ExportSuccess *prometheus.CounterVec // by sink (pcap/netflow/qingping/file)
+	ExportFailure *prometheus.CounterVec // by sink (pcap/netflow/qingping)
+
+	UDPReadLatency prometheus.Histogram
+	WorkerBusyTime prometheus.Histogram
+
+	GoroutineCount prometheus.GaugeFunc
+	QueueDepth     prometheus.Gauge
+	QueueDrops     prometheus.Counter
+}
+
+// New creates a Metrics with all collectors registered against a fresh
+// registry (rather than the global default one, so multiple *Server
+// instances in a test binary don't collide on collector registration).
+func New() *Metrics {
+	reg := prometheus.NewRegistry()
+
+	m := &Metrics{
+		registry: reg,
+		PacketsReceived: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "tzsp_packets_received_total",
+			Help: "TZSP packets received, by source IP.",
+		}, []string{"source_ip"}),
+		BytesReceived: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "tzsp_bytes_received_total",
+			Help: "Bytes received in TZSP packets, by source IP.",
+		}, []string{"source_ip"}),
+		PacketsByProto: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "tzsp_packets_by_protocol_total",
+			Help: "Decoded encapsulated packets, by L4 protocol.",
+		}, []string{"protocol"}),
+		DecodeErrors: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "tzsp_decode_errors_total",
+			Help: "Packet decode failures, by reason.",
+		}, []string{"reason"}),
+		ExportSuccess: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "tzsp_export_success_total",
+			Help: "Successful export calls, by sink.",
+		}, []string{"sink"}),
+		ExportFailure: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "tzsp_export_failure_total",
+			Help: "Failed export calls, by sink.",
+		}, []string{"sink"}),
+		UDPReadLatency: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "tzsp_udp_read_latency_seconds",
+			Help:    "Time spent blocked in ReadFromUDP waiting for a packet.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		WorkerBusyTime: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "tzsp_worker_busy_seconds",
+			Help:    "Time a pipeline worker spent inside processPacket for one job.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		QueueDepth: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "tzsp_queue_depth",
+			Help: "Current number of packets buffered awaiting a pipeline worker.",
+		}),
+		QueueDrops: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "tzsp_queue_drops_total",
+			Help: "Packets dropped because the pipeline queue was full (overflow_policy: drop_oldest).",
+		}),
+	}
+
+	m.GoroutineCount = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "tzsp_goroutines",
+		Help: "Current number of goroutines, as reported by runtime.NumGoroutine.",
+	}, func() float64 { return float64(runtime.NumGoroutine()) })
+
+	return m
+}
+
+// Server serves m's collectors over addr. Only collectors registered on
+// m's own registry are served; the Go/process collectors client_golang
+// registers on its default registry are not included. A zero-value addr means
+// metrics are disabled; callers should check that before calling New/Serve.
+type Server struct {
+	httpServer *http.Server
+}
+
+// Serve starts an HTTP listener on addr serving m at /metrics in the
+// background. Callers should defer Close to shut it down.
+func Serve(addr string, m *Metrics) (*Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	go srv.Serve(ln)
+
+	return &Server{httpServer: srv}, nil
+}
+
+// Close gracefully shuts down the metrics HTTP listener.
+func (s *Server) Close() error {
+	if s == nil || s.httpServer == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
@@ -0,0 +1,101 @@
+package mqtt
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Client is a minimal fire-and-forget MQTT 3.1.1 publisher: it opens a
+// TCP connection, sends CONNECT, and issues PUBLISH (QoS 0, no DUP/RETAIN)
+// packets. It does not read CONNACK/PUBACK, does not support QoS 1/2, and
+// does not reconnect on its own; callers that need resilience should treat
+// a Publish error as fatal and Dial a new Client.
+type Client struct {
+	conn     net.Conn
+	clientID string
+}
+
+// dialTimeout bounds how long Dial waits for the broker TCP connection.
+const dialTimeout = 5 * time.Second
+
+// Dial connects to addr ("host:port") and sends a CONNECT packet
+// identifying itself as clientID.
+func Dial(addr, clientID string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker %s: %w", addr, err)
+	}
+
+	c := &Client{conn: conn, clientID: clientID}
+	if err := c.sendConnect(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// sendConnect writes a 3.1.1 CONNECT packet with a 60s keepalive and no
+// credentials, will, or session persistence (CleanSession set).
+func (c *Client) sendConnect() error {
+	var variableHeader []byte
+	variableHeader = appendMQTTString(variableHeader, "MQTT")
+	variableHeader = append(variableHeader, protocolLevel311)
+	variableHeader = append(variableHeader, 0x02)       // connect flags: CleanSession
+	variableHeader = append(variableHeader, 0x00, 0x3C) // keep alive: 60s
+
+	payload := appendMQTTString(nil, c.clientID)
+
+	body := append(variableHeader, payload...)
+	packet := append([]byte{typeConnect << 4}, encodeRemainingLength(len(body))...)
+	packet = append(packet, body...)
+
+	_, err := c.conn.Write(packet)
+	return err
+}
+
+// Publish sends a PUBLISH packet for topic carrying payload, always as
+// QoS 0 with no Packet Identifier: QoS 1/2 are not supported, since this
+// Client never reads PUBACK/PUBREC.
+func (c *Client) Publish(topic string, payload []byte) error {
+	variableHeader := appendMQTTString(nil, topic)
+	body := append(variableHeader, payload...)
+
+	packet := append([]byte{typePublish << 4}, encodeRemainingLength(len(body))...)
+	packet = append(packet, body...)
+
+	_, err := c.conn.Write(packet)
+	return err
+}
+
+// Close closes the underlying TCP connection without sending DISCONNECT,
+// matching this Client's fire-and-forget design.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// appendMQTTString appends s as an MQTT "UTF-8 encoded string" (2-byte
+// big-endian length prefix, then the bytes) to dst.
+func appendMQTTString(dst []byte, s string) []byte {
+	dst = append(dst, byte(len(s)>>8), byte(len(s)))
+	return append(dst, s...)
+}
+
+// encodeRemainingLength encodes n using the MQTT variable-length
+// "Remaining Length" scheme (up to 4 bytes, 7 data bits per byte, top bit
+// is the continuation flag), the inverse of decodeRemainingLength.
+func encodeRemainingLength(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return out
+}
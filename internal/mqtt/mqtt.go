@@ -0,0 +1,257 @@
+// Package mqtt decodes MQTT PUBLISH packets (protocol versions 3.1.1 and
+// 5.0) from raw TCP payload bytes, buffering per-flow so a PUBLISH split
+// across multiple TCP segments is joined before parsing. This replaces
+// heuristic byte-scanning for topic/payload extraction with a decoder
+// that actually follows the MQTT fixed-header and variable-header
+// framing rules.
+package mqtt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// idleTimeout is how long a flow/connection can go without a Feed call
+// before its buffered bytes and negotiated protocol level are dropped.
+// Without this, a connection whose stream never completes (a crashed
+// peer, a one-way capture) would buffer forever.
+const idleTimeout = 5 * time.Minute
+
+// sweepInterval bounds how often Feed pays the cost of scanning for idle
+// entries, rather than doing it on every call.
+const sweepInterval = time.Minute
+
+// Packet types, from the top nibble of the MQTT fixed header's first byte.
+const (
+	typeConnect byte = 1
+	typePublish byte = 3
+)
+
+// protocolLevel values from a CONNECT packet's variable header, used to
+// tell a v5 PUBLISH (which carries a Properties block) from a 3.1.1 one.
+const (
+	protocolLevel311 byte = 4
+	protocolLevel5   byte = 5
+)
+
+// maxRemainingLength is the largest value the 4-byte variable-length
+// encoding can represent (128^4 - 1).
+const maxRemainingLength = 268435455
+
+// Message is a decoded MQTT PUBLISH packet.
+type Message struct {
+	Topic    string
+	PacketID uint16 // zero when QoS is 0, which carries no Packet Identifier
+	Payload  []byte
+	QoS      uint8
+	Retain   bool
+	Dup      bool
+}
+
+// Decoder reassembles and parses MQTT packets from TCP payload bytes fed
+// per flow. Feed is safe for concurrent use by multiple goroutines (e.g.
+// a worker pool that doesn't shard by flow): mu guards buffers and
+// protocolLevel. Feed also evicts entries idle for longer than
+// idleTimeout, so a flow/connection that never completes doesn't buffer
+// forever.
+type Decoder struct {
+	mu sync.Mutex
+	// buffers holds bytes not yet consumed into a complete packet, keyed
+	// by the directional flow (e.g. "SrcIP:SrcPort-DstIP:DstPort").
+	buffers map[string][]byte
+	// protocolLevel remembers the CONNECT-negotiated protocol level for a
+	// connection, keyed by an undirected connection key, so PUBLISH
+	// packets observed afterwards (in either direction) are parsed with
+	// the right variable header shape. Connections whose CONNECT wasn't
+	// captured default to 3.1.1 framing (no Properties block).
+	protocolLevel map[string]byte
+	// flowLastSeen/connLastSeen track the last Feed call for each
+	// buffers/protocolLevel key, so sweepIdle can evict entries nothing
+	// has used in a while.
+	flowLastSeen map[string]time.Time
+	connLastSeen map[string]time.Time
+	lastSweep    time.Time
+}
+
+// NewDecoder creates an empty Decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{
+		buffers:       make(map[string][]byte),
+		protocolLevel: make(map[string]byte),
+		flowLastSeen:  make(map[string]time.Time),
+		connLastSeen:  make(map[string]time.Time),
+	}
+}
+
+// Feed appends data, the latest TCP payload bytes observed for flowKey, to
+// that flow's buffer and extracts any complete PUBLISH packets now
+// available. connKey identifies the underlying connection regardless of
+// direction (e.g. CONNECT and PUBLISH on the same connection travel in
+// opposite directions) and is used to remember the negotiated protocol
+// version.
+func (d *Decoder) Feed(flowKey, connKey string, data []byte) ([]*Message, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	d.flowLastSeen[flowKey] = now
+	d.connLastSeen[connKey] = now
+	d.sweepIdle(now)
+
+	buf := append(d.buffers[flowKey], data...)
+
+	var messages []*Message
+
+	for {
+		if len(buf) < 2 {
+			break
+		}
+
+		remainingLength, lengthSize, ok := decodeRemainingLength(buf[1:])
+		if !ok {
+			if len(buf) > 5 {
+				// More than a byte plus a maximal 4-byte varint is
+				// present and we still couldn't decode it: the stream is
+				// desynchronized, not merely incomplete.
+				d.buffers[flowKey] = nil
+				return messages, fmt.Errorf("malformed remaining length in MQTT stream for flow %s", flowKey)
+			}
+			break
+		}
+
+		packetLen := 1 + lengthSize + remainingLength
+		if len(buf) < packetLen {
+			break // wait for more segments
+		}
+
+		packet := buf[:packetLen]
+		buf = buf[packetLen:]
+
+		packetType := packet[0] >> 4
+		flags := packet[0] & 0x0F
+		body := packet[1+lengthSize:]
+
+		switch packetType {
+		case typeConnect:
+			if level, ok := connectProtocolLevel(body); ok {
+				d.protocolLevel[connKey] = level
+			}
+		case typePublish:
+			level := d.protocolLevel[connKey]
+			msg, err := parsePublish(flags, level, body)
+			if err != nil {
+				continue // malformed PUBLISH; skip it, keep parsing the rest of the buffer
+			}
+			messages = append(messages, msg)
+		}
+	}
+
+	d.buffers[flowKey] = buf
+
+	return messages, nil
+}
+
+// sweepIdle drops buffers/protocolLevel entries nothing has fed in over
+// idleTimeout. Called from Feed (d.mu already held), and itself
+// rate-limited by sweepInterval so normal calls don't pay for a full map
+// scan.
+func (d *Decoder) sweepIdle(now time.Time) {
+	if now.Sub(d.lastSweep) < sweepInterval {
+		return
+	}
+	d.lastSweep = now
+
+	for key, seen := range d.flowLastSeen {
+		if now.Sub(seen) >= idleTimeout {
+			delete(d.flowLastSeen, key)
+			delete(d.buffers, key)
+		}
+	}
+	for key, seen := range d.connLastSeen {
+		if now.Sub(seen) >= idleTimeout {
+			delete(d.connLastSeen, key)
+			delete(d.protocolLevel, key)
+		}
+	}
+}
+
+// decodeRemainingLength decodes the MQTT variable-length "Remaining
+// Length" scheme (up to 4 bytes, 7 data bits per byte, top bit is the
+// continuation flag) from the start of data. ok is false if data doesn't
+// yet contain a complete encoding (more bytes may still be coming).
+func decodeRemainingLength(data []byte) (value, size int, ok bool) {
+	multiplier := 1
+	for i := 0; i < 4 && i < len(data); i++ {
+		b := data[i]
+		value += int(b&0x7F) * multiplier
+		if b&0x80 == 0 {
+			if value > maxRemainingLength {
+				return 0, 0, false
+			}
+			return value, i + 1, true
+		}
+		multiplier *= 128
+	}
+	return 0, 0, false
+}
+
+// connectProtocolLevel reads the Protocol Level byte from a CONNECT
+// packet's variable header (Protocol Name, then this one byte: 4 for
+// 3.1.1, 5 for 5.0).
+func connectProtocolLevel(body []byte) (byte, bool) {
+	if len(body) < 2 {
+		return 0, false
+	}
+	nameLen := int(binary.BigEndian.Uint16(body[0:2]))
+	levelOffset := 2 + nameLen
+	if len(body) <= levelOffset {
+		return 0, false
+	}
+	return body[levelOffset], true
+}
+
+// parsePublish decodes a PUBLISH packet's variable header and payload.
+// flags is the fixed header's bottom nibble; protocolLevel is the
+// connection's negotiated MQTT version (0 if unknown, treated as 3.1.1).
+func parsePublish(flags byte, protocolLevel byte, body []byte) (*Message, error) {
+	msg := &Message{
+		Retain: flags&0x01 != 0,
+		QoS:    (flags >> 1) & 0x03,
+		Dup:    flags&0x08 != 0,
+	}
+
+	if len(body) < 2 {
+		return nil, fmt.Errorf("PUBLISH too short for topic length")
+	}
+	topicLen := int(binary.BigEndian.Uint16(body[0:2]))
+	offset := 2 + topicLen
+	if len(body) < offset {
+		return nil, fmt.Errorf("PUBLISH too short for topic")
+	}
+	msg.Topic = string(body[2:offset])
+
+	if msg.QoS > 0 {
+		if len(body) < offset+2 {
+			return nil, fmt.Errorf("PUBLISH too short for packet identifier")
+		}
+		msg.PacketID = binary.BigEndian.Uint16(body[offset : offset+2])
+		offset += 2
+	}
+
+	if protocolLevel == protocolLevel5 {
+		propsLen, propsLenSize, ok := decodeRemainingLength(body[offset:])
+		if !ok {
+			return nil, fmt.Errorf("PUBLISH has malformed MQTT 5 property length")
+		}
+		offset += propsLenSize + propsLen
+		if len(body) < offset {
+			return nil, fmt.Errorf("PUBLISH too short for declared property length")
+		}
+	}
+
+	msg.Payload = body[offset:]
+
+	return msg, nil
+}
@@ -0,0 +1,206 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// buildPublish assembles a raw PUBLISH packet. packetID is only written
+// when qos > 0; props is appended as a pre-length-prefixed MQTT 5
+// Properties block when non-nil (an empty, non-nil slice encodes a
+// zero-length block).
+func buildPublish(topic string, qos uint8, packetID uint16, props, payload []byte) []byte {
+	var variable bytes.Buffer
+
+	topicLen := make([]byte, 2)
+	binary.BigEndian.PutUint16(topicLen, uint16(len(topic)))
+	variable.Write(topicLen)
+	variable.WriteString(topic)
+
+	if qos > 0 {
+		pid := make([]byte, 2)
+		binary.BigEndian.PutUint16(pid, packetID)
+		variable.Write(pid)
+	}
+
+	if props != nil {
+		variable.Write(encodeRemainingLength(len(props)))
+		variable.Write(props)
+	}
+
+	variable.Write(payload)
+
+	flags := byte(qos << 1)
+	header := byte(typePublish<<4) | flags
+
+	var out bytes.Buffer
+	out.WriteByte(header)
+	out.Write(encodeRemainingLength(variable.Len()))
+	out.Write(variable.Bytes())
+	return out.Bytes()
+}
+
+// buildConnect assembles just enough of a CONNECT packet's variable
+// header (Protocol Name, then Protocol Level) for connectProtocolLevel to
+// find the level.
+func buildConnect(level byte) []byte {
+	var variable bytes.Buffer
+	name := "MQTT"
+	nameLen := make([]byte, 2)
+	binary.BigEndian.PutUint16(nameLen, uint16(len(name)))
+	variable.Write(nameLen)
+	variable.WriteString(name)
+	variable.WriteByte(level)
+
+	var out bytes.Buffer
+	out.WriteByte(typeConnect << 4)
+	out.Write(encodeRemainingLength(variable.Len()))
+	out.Write(variable.Bytes())
+	return out.Bytes()
+}
+
+func TestDecoderFeedQoS0(t *testing.T) {
+	d := NewDecoder()
+	packet := buildPublish("sensors/temp", 0, 0, nil, []byte("22.5"))
+
+	messages, err := d.Feed("flow", "conn", packet)
+	if err != nil {
+		t.Fatalf("Feed returned error: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	msg := messages[0]
+	if msg.Topic != "sensors/temp" || string(msg.Payload) != "22.5" || msg.QoS != 0 || msg.PacketID != 0 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
+func TestDecoderFeedSplitAcrossSegments(t *testing.T) {
+	d := NewDecoder()
+	packet := buildPublish("sensors/temp", 0, 0, nil, []byte("22.5"))
+	split := len(packet) / 2
+
+	messages, err := d.Feed("flow", "conn", packet[:split])
+	if err != nil {
+		t.Fatalf("Feed returned error on first segment: %v", err)
+	}
+	if len(messages) != 0 {
+		t.Fatalf("expected no messages before the packet is complete, got %d", len(messages))
+	}
+
+	messages, err = d.Feed("flow", "conn", packet[split:])
+	if err != nil {
+		t.Fatalf("Feed returned error on second segment: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Topic != "sensors/temp" {
+		t.Fatalf("expected the completed message after the second segment, got %+v", messages)
+	}
+}
+
+func TestDecoderFeedQoS1PacketID(t *testing.T) {
+	d := NewDecoder()
+	packet := buildPublish("sensors/temp", 1, 42, nil, []byte("22.5"))
+
+	messages, err := d.Feed("flow", "conn", packet)
+	if err != nil {
+		t.Fatalf("Feed returned error: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if messages[0].QoS != 1 || messages[0].PacketID != 42 {
+		t.Fatalf("unexpected message: %+v", messages[0])
+	}
+}
+
+func TestDecoderFeedV5Properties(t *testing.T) {
+	d := NewDecoder()
+
+	// CONNECT negotiates protocol level 5 on the connection; PUBLISH then
+	// carries a Properties block that must be skipped, not parsed as
+	// payload.
+	connect := buildConnect(protocolLevel5)
+	if _, err := d.Feed("flow-a", "conn", connect); err != nil {
+		t.Fatalf("Feed(CONNECT) returned error: %v", err)
+	}
+
+	props := []byte{0x01, 0x00} // one made-up property TLV, contents don't matter to the decoder
+	packet := buildPublish("sensors/temp", 0, 0, props, []byte("22.5"))
+
+	messages, err := d.Feed("flow-b", "conn", packet)
+	if err != nil {
+		t.Fatalf("Feed(PUBLISH) returned error: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if messages[0].Topic != "sensors/temp" || string(messages[0].Payload) != "22.5" {
+		t.Fatalf("properties block leaked into topic/payload: %+v", messages[0])
+	}
+}
+
+func TestDecoderFeedMalformedRemainingLength(t *testing.T) {
+	d := NewDecoder()
+	// Fixed header byte, then 4 continuation-flagged bytes (the maximum
+	// varint width) that never terminate, plus one more byte so the
+	// buffer is long enough that this can't just be "more data needed".
+	packet := []byte{byte(typePublish << 4), 0xFF, 0xFF, 0xFF, 0xFF, 0x00}
+
+	_, err := d.Feed("flow", "conn", packet)
+	if err == nil {
+		t.Fatal("expected an error for a malformed remaining-length encoding")
+	}
+}
+
+func TestDecoderSweepIdleEvictsStaleEntries(t *testing.T) {
+	d := NewDecoder()
+
+	// Feed a partial PUBLISH that never completes, as on a connection
+	// whose peer vanished mid-stream.
+	packet := buildPublish("sensors/temp", 0, 0, nil, []byte("22.5"))
+	if _, err := d.Feed("flow", "conn", packet[:len(packet)/2]); err != nil {
+		t.Fatalf("Feed returned error: %v", err)
+	}
+	if len(d.buffers["flow"]) == 0 {
+		t.Fatal("expected the partial packet to be buffered")
+	}
+
+	// Back-date last-seen and the sweep cooldown so the next Feed call
+	// treats the flow/connection as idle without an actual sleep.
+	d.flowLastSeen["flow"] = time.Now().Add(-2 * idleTimeout)
+	d.connLastSeen["conn"] = time.Now().Add(-2 * idleTimeout)
+	d.lastSweep = time.Now().Add(-2 * sweepInterval)
+
+	// Feed on an unrelated flow/connection to trigger a sweep.
+	if _, err := d.Feed("other-flow", "other-conn", nil); err != nil {
+		t.Fatalf("Feed returned error: %v", err)
+	}
+
+	if _, ok := d.buffers["flow"]; ok {
+		t.Fatal("expected the idle flow's buffer to be evicted")
+	}
+	if _, ok := d.flowLastSeen["flow"]; ok {
+		t.Fatal("expected the idle flow's lastSeen entry to be evicted")
+	}
+	if _, ok := d.connLastSeen["conn"]; ok {
+		t.Fatal("expected the idle connection's lastSeen entry to be evicted")
+	}
+}
+
+func TestDecoderFeedIncompleteRemainingLengthWaitsForMore(t *testing.T) {
+	d := NewDecoder()
+	// Only 3 bytes total; not enough to know whether the length encoding
+	// is even complete yet, so Feed should neither error nor emit.
+	packet := []byte{byte(typePublish << 4), 0xFF, 0xFF}
+
+	messages, err := d.Feed("flow", "conn", packet)
+	if err != nil {
+		t.Fatalf("expected no error while awaiting more segments, got %v", err)
+	}
+	if len(messages) != 0 {
+		t.Fatalf("expected no messages yet, got %d", len(messages))
+	}
+}
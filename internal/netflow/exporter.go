@@ -1,6 +1,7 @@
 package netflow
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"net"
@@ -19,25 +20,111 @@ type Exporter struct {
 	conn          *net.UDPConn
 	flows         map[string]*Flow
 	mu            sync.Mutex
-	sequenceNum   uint32
+	sequenceNum   uint32 // v5 sequence number (flow record count)
+
+	// bidirectional enables biflow accounting: A->B and B->A packets are
+	// tracked on one Flow record instead of two. See Flow's PacketsAB/BA
+	// fields and exportBiflow.
+	bidirectional bool
+
+	// mtu, observationDomainID, and the fields below are only used by the
+	// v9 and IPFIX (v10) template-based export path; see templates.go.
+	mtu                  int
+	observationDomainID  uint32
+	startTime            time.Time
+	sequenceNumV9        uint32 // v9 sequence number (export packet count)
+	sequenceNumIPFIX     uint32 // IPFIX sequence number (export message count)
+	pendingRecords       []pendingRecord
+	lastTemplateSent     map[uint16]time.Time
+	recordsSinceTemplate map[uint16]int
+}
+
+// pendingRecord is a not-yet-flushed v9/IPFIX data record awaiting
+// batching into a Data FlowSet/Set.
+type pendingRecord struct {
+	templateID uint16
+	data       []byte
 }
 
+const (
+	// defaultMTU bounds how large a single v9/IPFIX export datagram can
+	// grow before pending records are flushed, matching the UDP payload
+	// size that stays safely under a 1500-byte Ethernet MTU after IP/UDP
+	// headers.
+	defaultMTU = 1400
+
+	// templateResendInterval and templateResendFlows bound how long a
+	// v9/IPFIX collector can go without seeing a (re-)sent template:
+	// whichever threshold is hit first triggers a resend.
+	templateResendInterval = 60 * time.Second
+	templateResendFlows    = 100
+)
+
+// FlowEndReason records why a flow record was exported, using the IPFIX
+// IE 136 (flowEndReason) code points so collectors can distinguish a
+// natural idle/active expiry from a TCP FIN/RST or a forced shutdown.
+type FlowEndReason uint8
+
+const (
+	EndReasonIdleTimeout   FlowEndReason = 1
+	EndReasonActiveTimeout FlowEndReason = 2
+	EndReasonEndOfFlow     FlowEndReason = 3 // TCP FIN or RST observed
+	EndReasonForcedEnd     FlowEndReason = 4 // exporter shutting down
+	// EndReasonAnomaly is not one of IPFIX's standard flowEndReason code
+	// points (1-5 per RFC 5102 are taken); it reuses the registry's
+	// "reserved" range to flag a flow cut short by an internal/anomaly
+	// capture trigger rather than a timeout, FIN/RST, or shutdown.
+	EndReasonAnomaly FlowEndReason = 6
+)
+
 // Flow represents a NetFlow flow record
 type Flow struct {
-	SrcIP     net.IP
-	DstIP     net.IP
-	SrcPort   uint16
-	DstPort   uint16
-	Protocol  uint8
-	FirstSeen time.Time
-	LastSeen  time.Time
-	Packets   uint32
-	Bytes     uint32
-	TCPFlags  uint8
-}
-
-// NewExporter creates a new NetFlow exporter
-func NewExporter(collectorAddr string, version int, flowTimeout, activeTimeout int) (*Exporter, error) {
+	SrcIP    net.IP
+	DstIP    net.IP
+	SrcPort  uint16
+	DstPort  uint16
+	Protocol uint8
+	// ToS is the IPv4 ToS / IPv6 traffic class byte common to all
+	// packets in the flow; it is part of the flow key alongside the
+	// 5-tuple so that differently-marked traffic between the same
+	// endpoints isn't collapsed into one record.
+	ToS             uint8
+	InputInterface  uint16
+	OutputInterface uint16
+	FirstSeen       time.Time
+	LastSeen        time.Time
+	// Packets, Bytes and TCPFlags accumulate traffic in the direction
+	// SrcIP/SrcPort -> DstIP/DstPort; used when Bidirectional is off.
+	Packets  uint32
+	Bytes    uint32
+	TCPFlags uint8
+	// PacketsAB/BytesAB/TCPFlagsAB and PacketsBA/BytesBA/TCPFlagsBA
+	// split traffic by direction relative to this flow's initiator (the
+	// SrcIP/SrcPort of the packet that created the record); used only
+	// when the Exporter's Bidirectional mode is on.
+	PacketsAB  uint32
+	BytesAB    uint32
+	TCPFlagsAB uint8
+	PacketsBA  uint32
+	BytesBA    uint32
+	TCPFlagsBA uint8
+	// EndReason is set immediately before export.
+	EndReason FlowEndReason
+}
+
+// IsIPv6 reports whether the flow's addresses are IPv6, so the v9/IPFIX
+// export path can pick the matching template.
+func (f *Flow) IsIPv6() bool {
+	return f.SrcIP.To4() == nil
+}
+
+// NewExporter creates a new NetFlow exporter. mtu and observationDomainID
+// only matter for version 9 and 10 (IPFIX): mtu bounds how many records
+// are batched into one export datagram, and observationDomainID
+// identifies this exporter to the collector (IPFIX Observation Domain
+// ID; reused as the v9 Source ID). Pass 0 for either to use their
+// defaults. bidirectional enables biflow accounting (see Exporter.bidirectional).
+func NewExporter(collectorAddr string, version int, flowTimeout, activeTimeout, mtu int, observationDomainID uint32, bidirectional bool) (*Exporter, error) {
 	addr, err := net.ResolveUDPAddr("udp", collectorAddr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve collector address: %w", err)
@@ -48,13 +135,23 @@ func NewExporter(collectorAddr string, version int, flowTimeout, activeTimeout i
 		return nil, fmt.Errorf("failed to connect to collector: %w", err)
 	}
 
+	if mtu <= 0 {
+		mtu = defaultMTU
+	}
+
 	e := &Exporter{
-		collectorAddr: collectorAddr,
-		version:       version,
-		flowTimeout:   time.Duration(flowTimeout) * time.Second,
-		activeTimeout: time.Duration(activeTimeout) * time.Second,
-		conn:          conn,
-		flows:         make(map[string]*Flow),
+		collectorAddr:        collectorAddr,
+		version:              version,
+		flowTimeout:          time.Duration(flowTimeout) * time.Second,
+		activeTimeout:        time.Duration(activeTimeout) * time.Second,
+		conn:                 conn,
+		flows:                make(map[string]*Flow),
+		bidirectional:        bidirectional,
+		mtu:                  mtu,
+		observationDomainID:  observationDomainID,
+		startTime:            time.Now(),
+		lastTemplateSent:     make(map[uint16]time.Time),
+		recordsSinceTemplate: make(map[uint16]int),
 	}
 
 	// Start flow expiration goroutine
@@ -84,6 +181,7 @@ func (e *Exporter) ProcessPacket(info *decoder.PacketInfo) error {
 			SrcPort:   info.SrcPort,
 			DstPort:   info.DstPort,
 			Protocol:  e.getProtocolNumber(info.Protocol),
+			ToS:       info.ToS,
 			FirstSeen: time.Unix(0, info.Timestamp),
 			LastSeen:  time.Unix(0, info.Timestamp),
 			Packets:   0,
@@ -94,13 +192,40 @@ func (e *Exporter) ProcessPacket(info *decoder.PacketInfo) error {
 
 	// Update flow
 	flow.LastSeen = time.Unix(0, info.Timestamp)
-	flow.Packets++
-	flow.Bytes += uint32(info.Length)
-	flow.TCPFlags |= e.parseTCPFlags(info.TCPFlags)
+	tcpFlags := e.parseTCPFlags(info.TCPFlags)
+
+	if e.bidirectional {
+		if info.SrcIP == flow.DstIP.String() && info.SrcPort == flow.DstPort {
+			// Packet travels B->A relative to the flow's initiator.
+			flow.PacketsBA++
+			flow.BytesBA += uint32(info.Length)
+			flow.TCPFlagsBA |= tcpFlags
+		} else {
+			flow.PacketsAB++
+			flow.BytesAB += uint32(info.Length)
+			flow.TCPFlagsAB |= tcpFlags
+		}
+	} else {
+		flow.Packets++
+		flow.Bytes += uint32(info.Length)
+		flow.TCPFlags |= tcpFlags
+	}
+
+	// FIN/RST ends the TCP conversation immediately; don't wait for the
+	// idle timeout to export and reclaim it.
+	const (
+		tcpFlagFIN = 0x01
+		tcpFlagRST = 0x04
+	)
+	if tcpFlags&(tcpFlagFIN|tcpFlagRST) != 0 {
+		e.exportFlow(flow, EndReasonEndOfFlow)
+		delete(e.flows, flowKey)
+		return nil
+	}
 
 	// Check for active timeout
 	if time.Since(flow.FirstSeen) >= e.activeTimeout {
-		e.exportFlow(flow)
+		e.exportFlow(flow, EndReasonActiveTimeout)
 		delete(e.flows, flowKey)
 	}
 
@@ -114,9 +239,10 @@ func (e *Exporter) Close() error {
 
 	// Export remaining flows
 	for key, flow := range e.flows {
-		e.exportFlow(flow)
+		e.exportFlow(flow, EndReasonForcedEnd)
 		delete(e.flows, key)
 	}
+	e.flushPending()
 
 	if e.conn != nil {
 		return e.conn.Close()
@@ -124,12 +250,50 @@ func (e *Exporter) Close() error {
 	return nil
 }
 
-// makeFlowKey creates a unique key for the flow
+// makeFlowKey creates a unique key for the flow. Besides the 5-tuple, ToS
+// is included so differently-marked traffic between the same endpoints
+// accumulates into separate flow records, matching how a real collector
+// would distinguish them. In Bidirectional mode, the endpoints are sorted
+// so a packet from either direction of the same conversation maps to the
+// same key; ProcessPacket then tells the directions apart by comparing
+// against the flow's recorded SrcIP/SrcPort (the initiator).
 func (e *Exporter) makeFlowKey(info *decoder.PacketInfo) string {
-	return fmt.Sprintf("%s:%d-%s:%d-%s",
-		info.SrcIP, info.SrcPort,
-		info.DstIP, info.DstPort,
-		info.Protocol)
+	return e.makeFlowKeyFromTuple(info.SrcIP, info.SrcPort, info.DstIP, info.DstPort, info.Protocol, info.ToS)
+}
+
+// makeFlowKeyFromTuple builds the flow key makeFlowKey derives from a
+// decoder.PacketInfo, taking the 5-tuple directly so callers that don't
+// have a PacketInfo on hand (e.g. FlagAnomaly) can look up a tracked flow.
+func (e *Exporter) makeFlowKeyFromTuple(srcIP string, srcPort uint16, dstIP string, dstPort uint16, protocol string, tos uint8) string {
+	if !e.bidirectional {
+		return fmt.Sprintf("%s:%d-%s:%d-%s-%d", srcIP, srcPort, dstIP, dstPort, protocol, tos)
+	}
+
+	a := fmt.Sprintf("%s:%d", srcIP, srcPort)
+	b := fmt.Sprintf("%s:%d", dstIP, dstPort)
+	if b < a {
+		a, b = b, a
+	}
+	return fmt.Sprintf("%s-%s-%s-%d", a, b, protocol, tos)
+}
+
+// FlagAnomaly immediately exports and evicts the tracked flow matching
+// this 5-tuple (if Bidirectional, either direction of it), stamping
+// EndReasonAnomaly so collectors can tell it was cut short by an anomaly
+// capture trigger (see internal/anomaly) rather than a timeout or
+// FIN/RST. Returns false if no flow is currently tracked for this tuple.
+func (e *Exporter) FlagAnomaly(srcIP string, srcPort uint16, dstIP string, dstPort uint16, protocol string, tos uint8) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := e.makeFlowKeyFromTuple(srcIP, srcPort, dstIP, dstPort, protocol, tos)
+	flow, ok := e.flows[key]
+	if !ok {
+		return false
+	}
+	e.exportFlow(flow, EndReasonAnomaly)
+	delete(e.flows, key)
+	return true
 }
 
 // getProtocolNumber converts protocol name to number
@@ -170,13 +334,287 @@ func (e *Exporter) parseTCPFlags(flags string) uint8 {
 	return result
 }
 
-// exportFlow exports a flow record using NetFlow v5
-func (e *Exporter) exportFlow(flow *Flow) error {
-	if e.version != 5 {
-		// Only NetFlow v5 is implemented for simplicity
+// exportFlow exports a single expired/closed flow record, in whichever
+// wire format e.version selects. v9 and IPFIX records are batched rather
+// than sent immediately; call flushPending to force them out.
+func (e *Exporter) exportFlow(flow *Flow, reason FlowEndReason) error {
+	flow.EndReason = reason
+
+	if e.bidirectional {
+		return e.exportBiflow(flow)
+	}
+	return e.exportUnidirectional(flow)
+}
+
+// exportUnidirectional exports flow using its Packets/Bytes/TCPFlags
+// (the non-biflow fields), in whichever wire format e.version selects.
+func (e *Exporter) exportUnidirectional(flow *Flow) error {
+	switch e.version {
+	case 5:
+		return e.exportFlowV5(flow)
+	case 9, 10:
+		return e.queueTemplateRecord(flow)
+	default:
+		return fmt.Errorf("unsupported NetFlow version: %d", e.version)
+	}
+}
+
+// exportBiflow exports a biflow-tracked flow. IPFIX (v10) emits a single
+// record carrying both directions via RFC 5103 reverse Information
+// Elements; v5 and v9 have no standard way to express a reverse direction
+// in one record, so each direction (if it saw any traffic) is exported as
+// its own unidirectional record instead.
+func (e *Exporter) exportBiflow(flow *Flow) error {
+	if e.version == 10 {
+		return e.queueBiflowTemplateRecord(flow)
+	}
+
+	ab, ba := splitBiflow(flow)
+	if ab.Packets > 0 {
+		if err := e.exportUnidirectional(ab); err != nil {
+			return err
+		}
+	}
+	if ba.Packets > 0 {
+		if err := e.exportUnidirectional(ba); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitBiflow builds the two unidirectional Flow views of a biflow
+// record's AB and BA counters, for exporters that can't carry reverse
+// Information Elements in one record.
+func splitBiflow(flow *Flow) (ab, ba *Flow) {
+	ab = &Flow{
+		SrcIP: flow.SrcIP, DstIP: flow.DstIP,
+		SrcPort: flow.SrcPort, DstPort: flow.DstPort,
+		Protocol: flow.Protocol, ToS: flow.ToS,
+		InputInterface: flow.InputInterface, OutputInterface: flow.OutputInterface,
+		FirstSeen: flow.FirstSeen, LastSeen: flow.LastSeen,
+		Packets: flow.PacketsAB, Bytes: flow.BytesAB, TCPFlags: flow.TCPFlagsAB,
+		EndReason: flow.EndReason,
+	}
+	ba = &Flow{
+		SrcIP: flow.DstIP, DstIP: flow.SrcIP,
+		SrcPort: flow.DstPort, DstPort: flow.SrcPort,
+		Protocol: flow.Protocol, ToS: flow.ToS,
+		InputInterface: flow.OutputInterface, OutputInterface: flow.InputInterface,
+		FirstSeen: flow.FirstSeen, LastSeen: flow.LastSeen,
+		Packets: flow.PacketsBA, Bytes: flow.BytesBA, TCPFlags: flow.TCPFlagsBA,
+		EndReason: flow.EndReason,
+	}
+	return ab, ba
+}
+
+// queueBiflowTemplateRecord encodes flow as an IPFIX Biflow data record
+// and appends it to the pending batch, flushing the batch first if adding
+// the record would overflow mtu.
+func (e *Exporter) queueBiflowTemplateRecord(flow *Flow) error {
+	templateID, _ := templateForBiflow(flow)
+	data := encodeBiflowDataRecord(flow)
+
+	// Count the largest message header plus one Set header per template.
+	if len(e.pendingRecords) > 0 && 20+4*flowSetHeaderLen+e.pendingSize()+len(data) > e.mtu {
+		if err := e.flushPending(); err != nil {
+			return err
+		}
+	}
+
+	e.pendingRecords = append(e.pendingRecords, pendingRecord{
+		templateID: templateID,
+		data:       data,
+	})
+	e.recordsSinceTemplate[templateID]++
+	return nil
+}
+
+// queueTemplateRecord encodes flow as a v9/IPFIX data record and appends
+// it to the pending batch, flushing the batch first if adding the record
+// would overflow mtu.
+func (e *Exporter) queueTemplateRecord(flow *Flow) error {
+	templateID, _ := templateFor(flow)
+	data := encodeDataRecord(flow)
+
+	// Count the largest message header plus one Set header per template.
+	if len(e.pendingRecords) > 0 && 20+4*flowSetHeaderLen+e.pendingSize()+len(data) > e.mtu {
+		if err := e.flushPending(); err != nil {
+			return err
+		}
+	}
+
+	e.pendingRecords = append(e.pendingRecords, pendingRecord{
+		templateID: templateID,
+		data:       data,
+	})
+	e.recordsSinceTemplate[templateID]++
+	return nil
+}
+
+// pendingSize estimates the encoded size of the current batch, used to
+// decide when to flush before exceeding mtu.
+func (e *Exporter) pendingSize() int {
+	size := 0
+	for _, rec := range e.pendingRecords {
+		size += len(rec.data)
+	}
+	return size
+}
+
+// flushPending sends all queued v9/IPFIX data records, plus any due
+// Template FlowSets/Sets, to the collector as one or more UDP datagrams
+// no larger than e.mtu. A no-op when nothing is pending.
+func (e *Exporter) flushPending() error {
+	if len(e.pendingRecords) == 0 {
 		return nil
 	}
 
+	now := time.Now()
+	var sets [][]byte
+
+	for _, templateID := range []uint16{templateIDv4, templateIDv6, templateIDv4Biflow, templateIDv6Biflow} {
+		if !e.hasPendingTemplate(templateID) || !e.templateDue(templateID, now) {
+			continue
+		}
+		sets = append(sets, e.buildTemplateSet(templateID))
+		e.lastTemplateSent[templateID] = now
+		e.recordsSinceTemplate[templateID] = 0
+	}
+
+	dataByTemplate := make(map[uint16]*bytes.Buffer)
+	var order []uint16
+	for _, rec := range e.pendingRecords {
+		buf, ok := dataByTemplate[rec.templateID]
+		if !ok {
+			buf = new(bytes.Buffer)
+			dataByTemplate[rec.templateID] = buf
+			order = append(order, rec.templateID)
+		}
+		buf.Write(rec.data)
+	}
+	for _, templateID := range order {
+		sets = append(sets, wrapSet(templateID, dataByTemplate[templateID].Bytes()))
+	}
+
+	e.pendingRecords = nil
+
+	return e.sendSets(sets)
+}
+
+// hasPendingTemplate reports whether any queued record uses templateID.
+func (e *Exporter) hasPendingTemplate(templateID uint16) bool {
+	for _, rec := range e.pendingRecords {
+		if rec.templateID == templateID {
+			return true
+		}
+	}
+	return false
+}
+
+// templateDue reports whether templateID's Template FlowSet/Set needs to
+// be (re-)sent: either it's never been sent, or one of the resend
+// thresholds has been crossed.
+func (e *Exporter) templateDue(templateID uint16, now time.Time) bool {
+	last, ok := e.lastTemplateSent[templateID]
+	if !ok {
+		return true
+	}
+	return now.Sub(last) >= templateResendInterval || e.recordsSinceTemplate[templateID] >= templateResendFlows
+}
+
+// buildTemplateSet builds the Template FlowSet (v9) or Template Set
+// (IPFIX) for templateID.
+func (e *Exporter) buildTemplateSet(templateID uint16) []byte {
+	var fields []templateField
+	switch templateID {
+	case templateIDv6:
+		fields = templateV6
+	case templateIDv4Biflow:
+		fields = templateV4Biflow
+	case templateIDv6Biflow:
+		fields = templateV6Biflow
+	default:
+		fields = templateV4
+	}
+
+	setID := uint16(0) // v9 Template FlowSet ID
+	if e.version == 10 {
+		setID = 2 // IPFIX Template Set ID
+	}
+
+	return wrapSet(setID, encodeTemplateRecord(templateID, fields))
+}
+
+// sendSets batches sets into one or more datagrams no larger than e.mtu
+// and writes each with its own header and sequence number.
+func (e *Exporter) sendSets(sets [][]byte) error {
+	headerSize := 20
+	if e.version == 10 {
+		headerSize = 16
+	}
+
+	var batch [][]byte
+	batchLen := headerSize
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		body := new(bytes.Buffer)
+		for _, s := range batch {
+			body.Write(s)
+		}
+		header := e.buildExportHeader(len(batch), headerSize+body.Len())
+		_, err := e.conn.Write(append(header, body.Bytes()...))
+		batch = nil
+		batchLen = headerSize
+		return err
+	}
+
+	for _, s := range sets {
+		if batchLen+len(s) > e.mtu && len(batch) > 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, s)
+		batchLen += len(s)
+	}
+	return flush()
+}
+
+// buildExportHeader builds the v9 or IPFIX message header. setCount is
+// the number of FlowSets/Sets in the message; totalLen is the full
+// message length in bytes, used only by the IPFIX header's Length field.
+func (e *Exporter) buildExportHeader(setCount, totalLen int) []byte {
+	if e.version == 10 {
+		buf := make([]byte, 16)
+		binary.BigEndian.PutUint16(buf[0:2], 10)
+		binary.BigEndian.PutUint16(buf[2:4], uint16(totalLen))
+		binary.BigEndian.PutUint32(buf[4:8], uint32(time.Now().Unix()))
+		e.sequenceNumIPFIX++
+		binary.BigEndian.PutUint32(buf[8:12], e.sequenceNumIPFIX)
+		binary.BigEndian.PutUint32(buf[12:16], e.observationDomainID)
+		return buf
+	}
+
+	buf := make([]byte, 20)
+	binary.BigEndian.PutUint16(buf[0:2], 9)
+	// Count is the number of FlowSets in this export packet rather than
+	// the total record count across them, a simplification in the same
+	// spirit as the v5 path (exportFlowV5, below) leaving engine type/ID unset.
+	binary.BigEndian.PutUint16(buf[2:4], uint16(setCount))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(time.Since(e.startTime).Milliseconds()))
+	binary.BigEndian.PutUint32(buf[8:12], uint32(time.Now().Unix()))
+	e.sequenceNumV9++
+	binary.BigEndian.PutUint32(buf[12:16], e.sequenceNumV9)
+	binary.BigEndian.PutUint32(buf[16:20], e.observationDomainID)
+	return buf
+}
+
+// exportFlowV5 exports a flow record using NetFlow v5
+func (e *Exporter) exportFlowV5(flow *Flow) error {
 	// NetFlow v5 header (24 bytes) + 1 record (48 bytes) = 72 bytes
 	buf := make([]byte, 72)
 
@@ -195,8 +633,8 @@ func (e *Exporter) exportFlow(flow *Flow) error {
 	copy(buf[offset:offset+4], flow.SrcIP.To4())
 	copy(buf[offset+4:offset+8], flow.DstIP.To4())
 	// Next hop = 0.0.0.0
-	binary.BigEndian.PutUint16(buf[offset+12:offset+14], 0) // Input interface
-	binary.BigEndian.PutUint16(buf[offset+14:offset+16], 0) // Output interface
+	binary.BigEndian.PutUint16(buf[offset+12:offset+14], flow.InputInterface)  // Input interface
+	binary.BigEndian.PutUint16(buf[offset+14:offset+16], flow.OutputInterface) // Output interface
 	binary.BigEndian.PutUint32(buf[offset+16:offset+20], flow.Packets)
 	binary.BigEndian.PutUint32(buf[offset+20:offset+24], flow.Bytes)
 	binary.BigEndian.PutUint32(buf[offset+24:offset+28], uint32(flow.FirstSeen.Unix()))
@@ -206,7 +644,7 @@ func (e *Exporter) exportFlow(flow *Flow) error {
 	buf[offset+36] = 0 // Pad
 	buf[offset+37] = flow.TCPFlags
 	buf[offset+38] = flow.Protocol
-	buf[offset+39] = 0 // TOS
+	buf[offset+39] = flow.ToS
 	// AS numbers and mask = 0
 
 	// Send to collector
@@ -224,10 +662,14 @@ func (e *Exporter) expireFlows() {
 		now := time.Now()
 		for key, flow := range e.flows {
 			if now.Sub(flow.LastSeen) >= e.flowTimeout {
-				e.exportFlow(flow)
+				e.exportFlow(flow, EndReasonIdleTimeout)
 				delete(e.flows, key)
 			}
 		}
+		// Bound how long v9/IPFIX records can sit batched before they
+		// reach the collector, even if the MTU-triggered flush in
+		// queueTemplateRecord never fires.
+		e.flushPending()
 		e.mu.Unlock()
 	}
 }
@@ -0,0 +1,182 @@
+package netflow
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pavelkim/tzsp_server/internal/decoder"
+)
+
+func newTestExporter(t *testing.T, version int, bidirectional bool) *Exporter {
+	t.Helper()
+	e, err := NewExporter("127.0.0.1:19999", version, 300, 300, 0, 0, bidirectional)
+	if err != nil {
+		t.Fatalf("NewExporter: %v", err)
+	}
+	t.Cleanup(func() { e.conn.Close() })
+	return e
+}
+
+func pktInfo(srcIP string, srcPort uint16, dstIP string, dstPort uint16, length int, flags string) *decoder.PacketInfo {
+	return &decoder.PacketInfo{
+		SrcIP: srcIP, SrcPort: srcPort,
+		DstIP: dstIP, DstPort: dstPort,
+		Protocol: "TCP", Length: length, TCPFlags: flags,
+		Timestamp: time.Now().UnixNano(),
+	}
+}
+
+func TestProcessPacketBidirectionalSplitsDirections(t *testing.T) {
+	e := newTestExporter(t, 10, true)
+
+	if err := e.ProcessPacket(pktInfo("10.0.0.1", 1234, "10.0.0.2", 443, 100, "S")); err != nil {
+		t.Fatalf("ProcessPacket (forward): %v", err)
+	}
+	if err := e.ProcessPacket(pktInfo("10.0.0.2", 443, "10.0.0.1", 1234, 200, "A")); err != nil {
+		t.Fatalf("ProcessPacket (reverse): %v", err)
+	}
+
+	if len(e.flows) != 1 {
+		t.Fatalf("expected both packets to collapse into 1 flow, got %d", len(e.flows))
+	}
+	var flow *Flow
+	for _, f := range e.flows {
+		flow = f
+	}
+
+	if flow.PacketsAB != 1 || flow.BytesAB != 100 {
+		t.Fatalf("AB counters: got packets=%d bytes=%d, want packets=1 bytes=100", flow.PacketsAB, flow.BytesAB)
+	}
+	if flow.PacketsBA != 1 || flow.BytesBA != 200 {
+		t.Fatalf("BA counters: got packets=%d bytes=%d, want packets=1 bytes=200", flow.PacketsBA, flow.BytesBA)
+	}
+	if flow.TCPFlagsAB != 0x02 {
+		t.Fatalf("TCPFlagsAB: got %#x, want SYN (0x02)", flow.TCPFlagsAB)
+	}
+	if flow.TCPFlagsBA != 0x10 {
+		t.Fatalf("TCPFlagsBA: got %#x, want ACK (0x10)", flow.TCPFlagsBA)
+	}
+}
+
+func TestMakeFlowKeyFromTupleBidirectionalSymmetric(t *testing.T) {
+	e := newTestExporter(t, 10, true)
+
+	forward := e.makeFlowKeyFromTuple("10.0.0.1", 1234, "10.0.0.2", 443, "TCP", 0)
+	reverse := e.makeFlowKeyFromTuple("10.0.0.2", 443, "10.0.0.1", 1234, "TCP", 0)
+	if forward != reverse {
+		t.Fatalf("bidirectional keys differ: forward=%q reverse=%q", forward, reverse)
+	}
+}
+
+func TestMakeFlowKeyFromTupleUnidirectionalDistinguishesDirection(t *testing.T) {
+	e := newTestExporter(t, 10, false)
+
+	forward := e.makeFlowKeyFromTuple("10.0.0.1", 1234, "10.0.0.2", 443, "TCP", 0)
+	reverse := e.makeFlowKeyFromTuple("10.0.0.2", 443, "10.0.0.1", 1234, "TCP", 0)
+	if forward == reverse {
+		t.Fatalf("unidirectional keys should differ by direction, both got %q", forward)
+	}
+}
+
+func TestSplitBiflow(t *testing.T) {
+	flow := &Flow{
+		SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP("10.0.0.2"),
+		SrcPort: 1234, DstPort: 443, Protocol: 6, ToS: 1,
+		InputInterface: 1, OutputInterface: 2,
+		PacketsAB: 3, BytesAB: 300, TCPFlagsAB: 0x02,
+		PacketsBA: 5, BytesBA: 500, TCPFlagsBA: 0x10,
+		EndReason: EndReasonIdleTimeout,
+	}
+
+	ab, ba := splitBiflow(flow)
+
+	if !ab.SrcIP.Equal(flow.SrcIP) || !ab.DstIP.Equal(flow.DstIP) || ab.SrcPort != flow.SrcPort || ab.DstPort != flow.DstPort {
+		t.Fatalf("ab endpoints: got %+v", ab)
+	}
+	if ab.Packets != flow.PacketsAB || ab.Bytes != flow.BytesAB || ab.TCPFlags != flow.TCPFlagsAB {
+		t.Fatalf("ab counters: got packets=%d bytes=%d flags=%#x, want packets=%d bytes=%d flags=%#x",
+			ab.Packets, ab.Bytes, ab.TCPFlags, flow.PacketsAB, flow.BytesAB, flow.TCPFlagsAB)
+	}
+	if ab.InputInterface != flow.InputInterface || ab.OutputInterface != flow.OutputInterface {
+		t.Fatalf("ab interfaces: got in=%d out=%d, want in=%d out=%d", ab.InputInterface, ab.OutputInterface, flow.InputInterface, flow.OutputInterface)
+	}
+
+	if !ba.SrcIP.Equal(flow.DstIP) || !ba.DstIP.Equal(flow.SrcIP) || ba.SrcPort != flow.DstPort || ba.DstPort != flow.SrcPort {
+		t.Fatalf("ba endpoints should be swapped, got %+v", ba)
+	}
+	if ba.Packets != flow.PacketsBA || ba.Bytes != flow.BytesBA || ba.TCPFlags != flow.TCPFlagsBA {
+		t.Fatalf("ba counters: got packets=%d bytes=%d flags=%#x, want packets=%d bytes=%d flags=%#x",
+			ba.Packets, ba.Bytes, ba.TCPFlags, flow.PacketsBA, flow.BytesBA, flow.TCPFlagsBA)
+	}
+	if ba.InputInterface != flow.OutputInterface || ba.OutputInterface != flow.InputInterface {
+		t.Fatalf("ba interfaces should be swapped, got in=%d out=%d", ba.InputInterface, ba.OutputInterface)
+	}
+}
+
+func TestExportBiflowV9SplitsIntoTwoUnidirectionalRecords(t *testing.T) {
+	e := newTestExporter(t, 9, true)
+
+	flow := &Flow{
+		SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP("10.0.0.2"),
+		SrcPort: 1234, DstPort: 443, Protocol: 6,
+		FirstSeen: time.Now(), LastSeen: time.Now(),
+		PacketsAB: 3, BytesAB: 300,
+		PacketsBA: 5, BytesBA: 500,
+	}
+
+	if err := e.exportFlow(flow, EndReasonIdleTimeout); err != nil {
+		t.Fatalf("exportFlow: %v", err)
+	}
+
+	if len(e.pendingRecords) != 2 {
+		t.Fatalf("v9 biflow export: expected 2 unidirectional records, got %d", len(e.pendingRecords))
+	}
+	for _, rec := range e.pendingRecords {
+		if rec.templateID != templateIDv4 {
+			t.Fatalf("v9 biflow export should use the unidirectional template, got %d", rec.templateID)
+		}
+	}
+}
+
+func TestExportBiflowIPFIXSingleRecord(t *testing.T) {
+	e := newTestExporter(t, 10, true)
+
+	flow := &Flow{
+		SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP("10.0.0.2"),
+		SrcPort: 1234, DstPort: 443, Protocol: 6,
+		FirstSeen: time.Now(), LastSeen: time.Now(),
+		PacketsAB: 3, BytesAB: 300,
+		PacketsBA: 5, BytesBA: 500,
+	}
+
+	if err := e.exportFlow(flow, EndReasonIdleTimeout); err != nil {
+		t.Fatalf("exportFlow: %v", err)
+	}
+
+	if len(e.pendingRecords) != 1 {
+		t.Fatalf("IPFIX biflow export: expected 1 record carrying both directions, got %d", len(e.pendingRecords))
+	}
+	if e.pendingRecords[0].templateID != templateIDv4Biflow {
+		t.Fatalf("IPFIX biflow export should use the biflow template, got %d", e.pendingRecords[0].templateID)
+	}
+}
+
+func TestExportBiflowOnlyOneDirectionSeenExportsOneRecord(t *testing.T) {
+	e := newTestExporter(t, 9, true)
+
+	flow := &Flow{
+		SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP("10.0.0.2"),
+		SrcPort: 1234, DstPort: 443, Protocol: 6,
+		FirstSeen: time.Now(), LastSeen: time.Now(),
+		PacketsAB: 3, BytesAB: 300,
+	}
+
+	if err := e.exportFlow(flow, EndReasonIdleTimeout); err != nil {
+		t.Fatalf("exportFlow: %v", err)
+	}
+
+	if len(e.pendingRecords) != 1 {
+		t.Fatalf("expected only the AB-direction record since BA saw no packets, got %d", len(e.pendingRecords))
+	}
+}
@@ -0,0 +1,232 @@
+package netflow
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// NetFlow v9 / IPFIX field type IDs. These are IANA IPFIX Information
+// Element numbers; NetFlow v9 reuses the same numbering for the fields
+// exported here.
+const (
+	fieldInBytes         = 1
+	fieldInPkts          = 2
+	fieldProtocol        = 4
+	fieldTCPFlags        = 6
+	fieldL4SrcPort       = 7
+	fieldIPv4SrcAddr     = 8
+	fieldInputSNMP       = 10
+	fieldL4DstPort       = 11
+	fieldIPv4DstAddr     = 12
+	fieldOutputSNMP      = 14
+	fieldIPv6SrcAddr     = 27
+	fieldIPv6DstAddr     = 28
+	fieldFlowEndReason   = 136
+	fieldFlowStartMillis = 152
+	fieldFlowEndMillis   = 153
+	fieldIPVersion       = 60
+)
+
+// reverseInformationElementPEN is the IANA-assigned Private Enterprise
+// Number reserved for "reverse" Information Elements (RFC 5103 §6.1).
+// A biflow's BA-direction counters are exported as the same element IDs
+// as their AB-direction counterparts (e.g. fieldInPkts), but flagged
+// with this enterprise number instead of being standard IANA elements.
+const reverseInformationElementPEN = 29305
+
+// templateField is one (type, length[, enterprise]) triplet of a Template
+// FlowSet (v9) or Template Set (IPFIX) record. enterprise is 0 for a
+// standard IANA element, or a Private Enterprise Number for an
+// enterprise-specific one (RFC 7011 §8.1), which also sets the Enterprise
+// bit on the encoded field type.
+type templateField struct {
+	fieldType  uint16
+	length     uint16
+	enterprise uint32
+}
+
+// Template IDs for the field layouts below. Values below 256 are
+// reserved for FlowSet/Set IDs, so template IDs start at 256 as required
+// by both specs. The Biflow templates are only ever sent over IPFIX
+// (version 10): v9 has no standardized way to carry RFC 5103 reverse
+// elements.
+const (
+	templateIDv4       uint16 = 256
+	templateIDv6       uint16 = 257
+	templateIDv4Biflow uint16 = 258
+	templateIDv6Biflow uint16 = 259
+)
+
+// templateV4 and templateV6 describe the fixed field layout exported for
+// IPv4 and IPv6 flows respectively. encodeDataRecord must write fields in
+// this exact order.
+var templateV4 = []templateField{
+	{fieldIPv4SrcAddr, 4, 0},
+	{fieldIPv4DstAddr, 4, 0},
+	{fieldL4SrcPort, 2, 0},
+	{fieldL4DstPort, 2, 0},
+	{fieldProtocol, 1, 0},
+	{fieldTCPFlags, 1, 0},
+	{fieldInPkts, 4, 0},
+	{fieldInBytes, 4, 0},
+	{fieldFlowStartMillis, 8, 0},
+	{fieldFlowEndMillis, 8, 0},
+	{fieldInputSNMP, 2, 0},
+	{fieldOutputSNMP, 2, 0},
+	{fieldIPVersion, 1, 0},
+	{fieldFlowEndReason, 1, 0},
+}
+
+var templateV6 = []templateField{
+	{fieldIPv6SrcAddr, 16, 0},
+	{fieldIPv6DstAddr, 16, 0},
+	{fieldL4SrcPort, 2, 0},
+	{fieldL4DstPort, 2, 0},
+	{fieldProtocol, 1, 0},
+	{fieldTCPFlags, 1, 0},
+	{fieldInPkts, 4, 0},
+	{fieldInBytes, 4, 0},
+	{fieldFlowStartMillis, 8, 0},
+	{fieldFlowEndMillis, 8, 0},
+	{fieldInputSNMP, 2, 0},
+	{fieldOutputSNMP, 2, 0},
+	{fieldIPVersion, 1, 0},
+	{fieldFlowEndReason, 1, 0},
+}
+
+// reverseFields is appended to templateV4/templateV6 to build the Biflow
+// templates: the BA-direction counterparts of fieldInBytes, fieldInPkts
+// and fieldTCPFlags, carried as RFC 5103 reverse Information Elements.
+var reverseFields = []templateField{
+	{fieldInBytes, 4, reverseInformationElementPEN},
+	{fieldInPkts, 4, reverseInformationElementPEN},
+	{fieldTCPFlags, 1, reverseInformationElementPEN},
+}
+
+var templateV4Biflow = append(append([]templateField{}, templateV4...), reverseFields...)
+var templateV6Biflow = append(append([]templateField{}, templateV6...), reverseFields...)
+
+// templateFor returns the template ID and field layout to use for flow.
+func templateFor(flow *Flow) (uint16, []templateField) {
+	if flow.IsIPv6() {
+		return templateIDv6, templateV6
+	}
+	return templateIDv4, templateV4
+}
+
+// templateForBiflow returns the Biflow template ID and field layout to
+// use for flow.
+func templateForBiflow(flow *Flow) (uint16, []templateField) {
+	if flow.IsIPv6() {
+		return templateIDv6Biflow, templateV6Biflow
+	}
+	return templateIDv4Biflow, templateV4Biflow
+}
+
+// encodeTemplateRecord encodes a single Template FlowSet / Template Set
+// record: TemplateID, FieldCount, then (Type, Length) per field.
+func encodeTemplateRecord(templateID uint16, fields []templateField) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, templateID)
+	binary.Write(buf, binary.BigEndian, uint16(len(fields)))
+	for _, f := range fields {
+		fieldType := f.fieldType
+		if f.enterprise != 0 {
+			fieldType |= 0x8000 // Enterprise bit, RFC 7011 §8.1
+		}
+		binary.Write(buf, binary.BigEndian, fieldType)
+		binary.Write(buf, binary.BigEndian, f.length)
+		if f.enterprise != 0 {
+			binary.Write(buf, binary.BigEndian, f.enterprise)
+		}
+	}
+	return buf.Bytes()
+}
+
+// encodeDataRecord encodes flow as a Data FlowSet / Data Set record
+// matching templateV4/templateV6's field order.
+func encodeDataRecord(flow *Flow) []byte {
+	buf := new(bytes.Buffer)
+
+	isV6 := flow.IsIPv6()
+	if isV6 {
+		buf.Write(flow.SrcIP.To16())
+		buf.Write(flow.DstIP.To16())
+	} else {
+		buf.Write(flow.SrcIP.To4())
+		buf.Write(flow.DstIP.To4())
+	}
+
+	binary.Write(buf, binary.BigEndian, flow.SrcPort)
+	binary.Write(buf, binary.BigEndian, flow.DstPort)
+	buf.WriteByte(flow.Protocol)
+	buf.WriteByte(flow.TCPFlags)
+	binary.Write(buf, binary.BigEndian, flow.Packets)
+	binary.Write(buf, binary.BigEndian, flow.Bytes)
+	binary.Write(buf, binary.BigEndian, uint64(flow.FirstSeen.UnixMilli()))
+	binary.Write(buf, binary.BigEndian, uint64(flow.LastSeen.UnixMilli()))
+	binary.Write(buf, binary.BigEndian, flow.InputInterface)
+	binary.Write(buf, binary.BigEndian, flow.OutputInterface)
+	if isV6 {
+		buf.WriteByte(6)
+	} else {
+		buf.WriteByte(4)
+	}
+	buf.WriteByte(byte(flow.EndReason))
+
+	return buf.Bytes()
+}
+
+// encodeBiflowDataRecord encodes flow as a Biflow Data Set record
+// matching templateV4Biflow/templateV6Biflow's field order: the same
+// fields as encodeDataRecord, using the AB-direction counters, followed
+// by the BA-direction counters as reverse Information Elements.
+func encodeBiflowDataRecord(flow *Flow) []byte {
+	buf := new(bytes.Buffer)
+
+	isV6 := flow.IsIPv6()
+	if isV6 {
+		buf.Write(flow.SrcIP.To16())
+		buf.Write(flow.DstIP.To16())
+	} else {
+		buf.Write(flow.SrcIP.To4())
+		buf.Write(flow.DstIP.To4())
+	}
+
+	binary.Write(buf, binary.BigEndian, flow.SrcPort)
+	binary.Write(buf, binary.BigEndian, flow.DstPort)
+	buf.WriteByte(flow.Protocol)
+	buf.WriteByte(flow.TCPFlagsAB)
+	binary.Write(buf, binary.BigEndian, flow.PacketsAB)
+	binary.Write(buf, binary.BigEndian, flow.BytesAB)
+	binary.Write(buf, binary.BigEndian, uint64(flow.FirstSeen.UnixMilli()))
+	binary.Write(buf, binary.BigEndian, uint64(flow.LastSeen.UnixMilli()))
+	binary.Write(buf, binary.BigEndian, flow.InputInterface)
+	binary.Write(buf, binary.BigEndian, flow.OutputInterface)
+	if isV6 {
+		buf.WriteByte(6)
+	} else {
+		buf.WriteByte(4)
+	}
+	buf.WriteByte(byte(flow.EndReason))
+
+	binary.Write(buf, binary.BigEndian, flow.BytesBA)
+	binary.Write(buf, binary.BigEndian, flow.PacketsBA)
+	buf.WriteByte(flow.TCPFlagsBA)
+
+	return buf.Bytes()
+}
+
+// flowSetHeaderLen is the size of a v9 FlowSet / IPFIX Set header
+// (ID, Length), common to both template and data sets.
+const flowSetHeaderLen = 4
+
+// wrapSet prepends a (ID, Length) header to body, producing a complete
+// FlowSet (v9) or Set (IPFIX).
+func wrapSet(id uint16, body []byte) []byte {
+	set := make([]byte, flowSetHeaderLen+len(body))
+	binary.BigEndian.PutUint16(set[0:2], id)
+	binary.BigEndian.PutUint16(set[2:4], uint16(len(set)))
+	copy(set[flowSetHeaderLen:], body)
+	return set
+}
@@ -0,0 +1,229 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEncodeTemplateRecord(t *testing.T) {
+	fields := []templateField{
+		{fieldIPv4SrcAddr, 4, 0},
+		{fieldInBytes, 4, reverseInformationElementPEN},
+	}
+	got := encodeTemplateRecord(templateIDv4, fields)
+
+	if len(got) != 4+4+8 {
+		t.Fatalf("unexpected length: got %d, want %d", len(got), 4+4+8)
+	}
+	if id := binary.BigEndian.Uint16(got[0:2]); id != templateIDv4 {
+		t.Fatalf("template ID: got %d, want %d", id, templateIDv4)
+	}
+	if count := binary.BigEndian.Uint16(got[2:4]); count != uint16(len(fields)) {
+		t.Fatalf("field count: got %d, want %d", count, len(fields))
+	}
+
+	// First field: standard IANA element, no Enterprise bit, no PEN suffix.
+	if ftype := binary.BigEndian.Uint16(got[4:6]); ftype != fieldIPv4SrcAddr {
+		t.Fatalf("field 1 type: got %#x, want %#x", ftype, fieldIPv4SrcAddr)
+	}
+	if flen := binary.BigEndian.Uint16(got[6:8]); flen != 4 {
+		t.Fatalf("field 1 length: got %d, want 4", flen)
+	}
+
+	// Second field: reverse element, Enterprise bit set plus a 4-byte PEN.
+	ftype := binary.BigEndian.Uint16(got[8:10])
+	if ftype&0x8000 == 0 {
+		t.Fatalf("field 2 type %#x missing Enterprise bit", ftype)
+	}
+	if ftype&0x7fff != fieldInBytes {
+		t.Fatalf("field 2 type: got %#x, want low bits %#x", ftype, fieldInBytes)
+	}
+	if flen := binary.BigEndian.Uint16(got[10:12]); flen != 4 {
+		t.Fatalf("field 2 length: got %d, want 4", flen)
+	}
+	if pen := binary.BigEndian.Uint32(got[12:16]); pen != reverseInformationElementPEN {
+		t.Fatalf("field 2 PEN: got %d, want %d", pen, reverseInformationElementPEN)
+	}
+}
+
+func testFlowV4() *Flow {
+	firstSeen := time.Unix(1700000000, 0)
+	return &Flow{
+		SrcIP:           net.ParseIP("10.0.0.1"),
+		DstIP:           net.ParseIP("10.0.0.2"),
+		SrcPort:         1234,
+		DstPort:         443,
+		Protocol:        6,
+		InputInterface:  1,
+		OutputInterface: 2,
+		FirstSeen:       firstSeen,
+		LastSeen:        firstSeen.Add(5 * time.Second),
+		Packets:         10,
+		Bytes:           1500,
+		TCPFlags:        0x18,
+		EndReason:       EndReasonIdleTimeout,
+	}
+}
+
+func TestEncodeDataRecordV4(t *testing.T) {
+	flow := testFlowV4()
+	got := encodeDataRecord(flow)
+
+	wantLen := 4 + 4 + 2 + 2 + 1 + 1 + 4 + 4 + 8 + 8 + 2 + 2 + 1 + 1
+	if len(got) != wantLen {
+		t.Fatalf("unexpected length: got %d, want %d", len(got), wantLen)
+	}
+
+	off := 0
+	if ip := net.IP(got[off : off+4]); !ip.Equal(flow.SrcIP) {
+		t.Fatalf("SrcIP: got %v, want %v", ip, flow.SrcIP)
+	}
+	off += 4
+	if ip := net.IP(got[off : off+4]); !ip.Equal(flow.DstIP) {
+		t.Fatalf("DstIP: got %v, want %v", ip, flow.DstIP)
+	}
+	off += 4
+	if p := binary.BigEndian.Uint16(got[off : off+2]); p != flow.SrcPort {
+		t.Fatalf("SrcPort: got %d, want %d", p, flow.SrcPort)
+	}
+	off += 2
+	if p := binary.BigEndian.Uint16(got[off : off+2]); p != flow.DstPort {
+		t.Fatalf("DstPort: got %d, want %d", p, flow.DstPort)
+	}
+	off += 2
+	if got[off] != flow.Protocol {
+		t.Fatalf("Protocol: got %d, want %d", got[off], flow.Protocol)
+	}
+	off++
+	if got[off] != flow.TCPFlags {
+		t.Fatalf("TCPFlags: got %#x, want %#x", got[off], flow.TCPFlags)
+	}
+	off++
+	if v := binary.BigEndian.Uint32(got[off : off+4]); v != flow.Packets {
+		t.Fatalf("Packets: got %d, want %d", v, flow.Packets)
+	}
+	off += 4
+	if v := binary.BigEndian.Uint32(got[off : off+4]); v != flow.Bytes {
+		t.Fatalf("Bytes: got %d, want %d", v, flow.Bytes)
+	}
+	off += 4
+	if v := binary.BigEndian.Uint64(got[off : off+8]); v != uint64(flow.FirstSeen.UnixMilli()) {
+		t.Fatalf("FirstSeen: got %d, want %d", v, flow.FirstSeen.UnixMilli())
+	}
+	off += 8
+	if v := binary.BigEndian.Uint64(got[off : off+8]); v != uint64(flow.LastSeen.UnixMilli()) {
+		t.Fatalf("LastSeen: got %d, want %d", v, flow.LastSeen.UnixMilli())
+	}
+	off += 8
+	if v := binary.BigEndian.Uint16(got[off : off+2]); v != flow.InputInterface {
+		t.Fatalf("InputInterface: got %d, want %d", v, flow.InputInterface)
+	}
+	off += 2
+	if v := binary.BigEndian.Uint16(got[off : off+2]); v != flow.OutputInterface {
+		t.Fatalf("OutputInterface: got %d, want %d", v, flow.OutputInterface)
+	}
+	off += 2
+	if got[off] != 4 {
+		t.Fatalf("IPVersion: got %d, want 4", got[off])
+	}
+	off++
+	if got[off] != byte(flow.EndReason) {
+		t.Fatalf("EndReason: got %d, want %d", got[off], byte(flow.EndReason))
+	}
+}
+
+func TestEncodeDataRecordV6(t *testing.T) {
+	flow := testFlowV4()
+	flow.SrcIP = net.ParseIP("2001:db8::1")
+	flow.DstIP = net.ParseIP("2001:db8::2")
+
+	got := encodeDataRecord(flow)
+
+	if !net.IP(got[0:16]).Equal(flow.SrcIP) {
+		t.Fatalf("SrcIP: got %v, want %v", net.IP(got[0:16]), flow.SrcIP)
+	}
+	if !net.IP(got[16:32]).Equal(flow.DstIP) {
+		t.Fatalf("DstIP: got %v, want %v", net.IP(got[16:32]), flow.DstIP)
+	}
+	if verByte := got[len(got)-2]; verByte != 6 {
+		t.Fatalf("IPVersion: got %d, want 6", verByte)
+	}
+}
+
+func TestEncodeBiflowDataRecord(t *testing.T) {
+	flow := testFlowV4()
+	flow.TCPFlagsAB = 0x02
+	flow.PacketsAB = 10
+	flow.BytesAB = 1500
+	flow.TCPFlagsBA = 0x12
+	flow.PacketsBA = 7
+	flow.BytesBA = 900
+
+	got := encodeBiflowDataRecord(flow)
+
+	// Same layout as encodeDataRecord up through EndReason, using the AB
+	// counters, then BytesBA, PacketsBA, TCPFlagsBA appended.
+	forward := encodeDataRecord(&Flow{
+		SrcIP: flow.SrcIP, DstIP: flow.DstIP,
+		SrcPort: flow.SrcPort, DstPort: flow.DstPort,
+		Protocol: flow.Protocol, TCPFlags: flow.TCPFlagsAB,
+		Packets: flow.PacketsAB, Bytes: flow.BytesAB,
+		FirstSeen: flow.FirstSeen, LastSeen: flow.LastSeen,
+		InputInterface: flow.InputInterface, OutputInterface: flow.OutputInterface,
+		EndReason: flow.EndReason,
+	})
+	if string(got[:len(forward)]) != string(forward) {
+		t.Fatalf("AB-direction prefix mismatch:\ngot  %x\nwant %x", got[:len(forward)], forward)
+	}
+
+	tail := got[len(forward):]
+	if len(tail) != 4+4+1 {
+		t.Fatalf("reverse tail length: got %d, want %d", len(tail), 4+4+1)
+	}
+	if v := binary.BigEndian.Uint32(tail[0:4]); v != flow.BytesBA {
+		t.Fatalf("BytesBA: got %d, want %d", v, flow.BytesBA)
+	}
+	if v := binary.BigEndian.Uint32(tail[4:8]); v != flow.PacketsBA {
+		t.Fatalf("PacketsBA: got %d, want %d", v, flow.PacketsBA)
+	}
+	if tail[8] != flow.TCPFlagsBA {
+		t.Fatalf("TCPFlagsBA: got %#x, want %#x", tail[8], flow.TCPFlagsBA)
+	}
+}
+
+func TestTemplateFor(t *testing.T) {
+	v4 := testFlowV4()
+	if id, fields := templateFor(v4); id != templateIDv4 || len(fields) != len(templateV4) {
+		t.Fatalf("v4: got id=%d fields=%d, want id=%d fields=%d", id, len(fields), templateIDv4, len(templateV4))
+	}
+
+	v6 := testFlowV4()
+	v6.SrcIP = net.ParseIP("2001:db8::1")
+	if id, fields := templateFor(v6); id != templateIDv6 || len(fields) != len(templateV6) {
+		t.Fatalf("v6: got id=%d fields=%d, want id=%d fields=%d", id, len(fields), templateIDv6, len(templateV6))
+	}
+
+	if id, fields := templateForBiflow(v4); id != templateIDv4Biflow || len(fields) != len(templateV4Biflow) {
+		t.Fatalf("v4 biflow: got id=%d fields=%d, want id=%d fields=%d", id, len(fields), templateIDv4Biflow, len(templateV4Biflow))
+	}
+}
+
+func TestWrapSet(t *testing.T) {
+	body := []byte{0x01, 0x02, 0x03}
+	got := wrapSet(templateIDv4, body)
+
+	if len(got) != flowSetHeaderLen+len(body) {
+		t.Fatalf("length: got %d, want %d", len(got), flowSetHeaderLen+len(body))
+	}
+	if id := binary.BigEndian.Uint16(got[0:2]); id != templateIDv4 {
+		t.Fatalf("set ID: got %d, want %d", id, templateIDv4)
+	}
+	if l := binary.BigEndian.Uint16(got[2:4]); l != uint16(len(got)) {
+		t.Fatalf("set length: got %d, want %d", l, len(got))
+	}
+	if string(got[4:]) != string(body) {
+		t.Fatalf("body: got %x, want %x", got[4:], body)
+	}
+}
@@ -0,0 +1,94 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// HTTPParser parses HTTP/1.1 request and response lines plus headers,
+// buffering per sessionKey until a full message is available. It does
+// not decode HTTP/2; a session that negotiates h2 will simply never
+// produce a complete parse and its bytes accumulate until the session is
+// dropped by the caller.
+type HTTPParser struct {
+	mu      sync.Mutex
+	buffers map[string][]byte
+}
+
+// NewHTTPParser creates an empty HTTPParser.
+func NewHTTPParser() *HTTPParser {
+	return &HTTPParser{buffers: make(map[string][]byte)}
+}
+
+// Name implements Parser.
+func (p *HTTPParser) Name() string { return "http" }
+
+// Parse implements Parser, dispatching to http.ReadRequest or
+// http.ReadResponse depending on isRequest.
+func (p *HTTPParser) Parse(sessionKey string, isRequest bool, data []byte) ([]byte, map[string]string, error) {
+	p.mu.Lock()
+	buf := append(p.buffers[sessionKey], data...)
+	p.mu.Unlock()
+
+	reader := bufio.NewReader(bytes.NewReader(buf))
+
+	var fields map[string]string
+	if isRequest {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return p.keepBuffering(sessionKey, buf, err)
+		}
+		body, _ := io.ReadAll(req.Body)
+		fields = map[string]string{
+			"method": req.Method,
+			"url":    req.URL.String(),
+			"host":   req.Host,
+			"body":   string(body),
+		}
+	} else {
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			return p.keepBuffering(sessionKey, buf, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		fields = map[string]string{
+			"status": resp.Status,
+			"body":   string(body),
+		}
+	}
+
+	rest := drainRemaining(reader)
+	p.mu.Lock()
+	p.buffers[sessionKey] = rest
+	p.mu.Unlock()
+
+	return rest, fields, nil
+}
+
+// keepBuffering handles an incomplete parse (the common case: a message
+// still being assembled from more TCP segments) versus a genuinely
+// malformed message, which is reported as an error so the caller can
+// drop the session.
+func (p *HTTPParser) keepBuffering(sessionKey string, buf []byte, err error) ([]byte, map[string]string, error) {
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		p.mu.Lock()
+		p.buffers[sessionKey] = buf
+		p.mu.Unlock()
+		return buf, nil, nil
+	}
+	p.mu.Lock()
+	delete(p.buffers, sessionKey)
+	p.mu.Unlock()
+	return nil, nil, fmt.Errorf("http: %w", err)
+}
+
+// drainRemaining reads whatever bufio.Reader has left buffered or
+// unread, i.e. the bytes after the message Parse just consumed.
+func drainRemaining(r *bufio.Reader) []byte {
+	rest, _ := io.ReadAll(r)
+	return rest
+}
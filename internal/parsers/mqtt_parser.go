@@ -0,0 +1,47 @@
+package parsers
+
+import (
+	"fmt"
+
+	"github.com/pavelkim/tzsp_server/internal/mqtt"
+)
+
+// MQTTParser adapts internal/mqtt's PUBLISH decoder to the Parser
+// interface. sessionKey doubles as both the mqtt.Decoder flow key and
+// connection key: MQTT PUBLISH framing doesn't depend on the CONNECT
+// direction, only on whether a CONNECT on this connection negotiated v5.
+type MQTTParser struct {
+	decoder *mqtt.Decoder
+}
+
+// NewMQTTParser creates an MQTTParser with its own reassembly state.
+func NewMQTTParser() *MQTTParser {
+	return &MQTTParser{decoder: mqtt.NewDecoder()}
+}
+
+// Name implements Parser.
+func (p *MQTTParser) Name() string { return "mqtt" }
+
+// Parse implements Parser. Every complete PUBLISH found becomes a fields
+// map ("topic", "payload", "qos", "retain", "dup"); if Parse finds more
+// than one PUBLISH in a single call, only the fields of the last one are
+// returned, since Parser.Parse yields a single fields map per call.
+func (p *MQTTParser) Parse(sessionKey string, isRequest bool, data []byte) ([]byte, map[string]string, error) {
+	messages, err := p.decoder.Feed(sessionKey, sessionKey, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mqtt: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, nil, nil
+	}
+
+	msg := messages[len(messages)-1]
+	fields := map[string]string{
+		"topic":   msg.Topic,
+		"payload": string(msg.Payload),
+		"qos":     fmt.Sprintf("%d", msg.QoS),
+		"retain":  fmt.Sprintf("%t", msg.Retain),
+		"dup":     fmt.Sprintf("%t", msg.Dup),
+	}
+	return nil, fields, nil
+}
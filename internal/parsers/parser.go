@@ -0,0 +1,22 @@
+// Package parsers implements a pluggable L7 protocol parser subsystem: a
+// Registry maps an L4 protocol and port to a Parser, the server looks up
+// the right one per flow, and additional parsers can be loaded at
+// startup from Go plugin shared objects instead of being compiled in.
+package parsers
+
+// Parser extracts application-layer fields from one direction's buffered
+// bytes for a session. Implementations are expected to keep their own
+// per-sessionKey reassembly state, since a single TCP segment rarely
+// lines up with one protocol message.
+//
+// Parse consumes as much of data as it can turn into complete messages,
+// returning the leftover bytes (rest) that belong to a message still in
+// progress, the fields extracted from any messages it completed, and an
+// error only when the stream is unrecoverably desynchronized (in which
+// case the caller should drop the session rather than keep feeding it).
+// fields is nil when no complete message was parsed from this call.
+type Parser interface {
+	// Name identifies this parser in logs and registry listings.
+	Name() string
+	Parse(sessionKey string, isRequest bool, data []byte) (rest []byte, fields map[string]string, err error)
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package parsers
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// registerParserSymbol is the name a plugin shared object must export: a
+// func(*Registry) error that registers whatever Parser(s) it provides.
+const registerParserSymbol = "RegisterParser"
+
+// LoadPlugins opens each Go plugin shared object in paths and calls its
+// RegisterParser(reg) entry point. A plugin that doesn't export that
+// symbol, or whose signature doesn't match, is reported as an error
+// rather than skipped, since a misnamed plugin path is almost always a
+// config mistake worth surfacing.
+func LoadPlugins(paths []string, reg *Registry) error {
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("parsers: failed to open plugin %q: %w", path, err)
+		}
+
+		sym, err := p.Lookup(registerParserSymbol)
+		if err != nil {
+			return fmt.Errorf("parsers: plugin %q has no %s symbol: %w", path, registerParserSymbol, err)
+		}
+
+		register, ok := sym.(func(*Registry) error)
+		if !ok {
+			return fmt.Errorf("parsers: plugin %q's %s has the wrong signature", path, registerParserSymbol)
+		}
+
+		if err := register(reg); err != nil {
+			return fmt.Errorf("parsers: plugin %q failed to register: %w", path, err)
+		}
+	}
+	return nil
+}
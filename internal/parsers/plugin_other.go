@@ -0,0 +1,15 @@
+//go:build !linux
+
+package parsers
+
+import "fmt"
+
+// LoadPlugins is unavailable on non-Linux platforms: the Go plugin
+// package only supports linux. A non-empty paths list is reported as a
+// config-time error rather than silently ignored.
+func LoadPlugins(paths []string, reg *Registry) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	return fmt.Errorf("parsers: plugin loading is only supported on linux (configured %d plugin(s))", len(paths))
+}
@@ -0,0 +1,21 @@
+package parsers
+
+// RawParser is the fallback for flows with no registered protocol
+// parser: it surfaces the payload verbatim without attempting to frame
+// it into messages.
+type RawParser struct{}
+
+// NewRawParser creates a RawParser.
+func NewRawParser() *RawParser { return &RawParser{} }
+
+// Name implements Parser.
+func (p *RawParser) Name() string { return "raw" }
+
+// Parse implements Parser, returning every call's data as a single
+// "payload" field and never buffering anything as rest.
+func (p *RawParser) Parse(sessionKey string, isRequest bool, data []byte) ([]byte, map[string]string, error) {
+	if len(data) == 0 {
+		return nil, nil, nil
+	}
+	return nil, map[string]string{"payload": string(data)}, nil
+}
@@ -0,0 +1,43 @@
+package parsers
+
+import "sync"
+
+// portKey identifies a registered Parser by L4 protocol and port. Port 0
+// is a protocol-wide fallback, consulted when no exact port match exists.
+type portKey struct {
+	proto string
+	port  uint16
+}
+
+// Registry looks up a Parser for a flow by L4 protocol and port. It is
+// safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	parsers map[portKey]Parser
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{parsers: make(map[portKey]Parser)}
+}
+
+// Register associates p with proto (e.g. "TCP", "UDP") and port. A port
+// of 0 registers p as the fallback for any port on that protocol.
+func (r *Registry) Register(proto string, port uint16, p Parser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parsers[portKey{proto: proto, port: port}] = p
+}
+
+// Lookup returns the Parser registered for proto and port, falling back
+// to proto's port-0 entry if no exact match exists.
+func (r *Registry) Lookup(proto string, port uint16) (Parser, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if p, ok := r.parsers[portKey{proto: proto, port: port}]; ok {
+		return p, true
+	}
+	p, ok := r.parsers[portKey{proto: proto, port: 0}]
+	return p, ok
+}
@@ -1,6 +1,7 @@
 package pcap
 
 import (
+	"encoding/binary"
 	"fmt"
 	"os"
 	"sync"
@@ -9,23 +10,69 @@ import (
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcapgo"
+
+	"github.com/pavelkim/tzsp_server/internal/tzsp"
+)
+
+// Output formats supported by Writer.
+const (
+	FormatPCAP   = "pcap"
+	FormatPCAPNG = "pcapng"
+)
+
+// PCAPNG Enhanced Packet Block option codes. optCustomNoCopy (2989) marks
+// vendor data that readers which don't understand it should skip rather
+// than try to preserve across edits, which is the right behaviour for
+// radio metadata reconstructed from TZSP tags.
+const (
+	optEndOfOpt     = 0
+	optCustomNoCopy = 2989
+
+	ngBlockTypeEnhancedPacket = 0x00000006
+)
+
+// tzspPEN is a placeholder Private Enterprise Number used to namespace
+// the custom EPB options carrying TZSP tag data. It is not a registered
+// IANA PEN; it only needs to be stable within files this writer produces.
+const tzspPEN = 0
+
+// Sub-types carried inside the opt_custom option value, after the 4-byte
+// PEN, as a simple type-length-value sequence.
+const (
+	tzspOptRSSI     = 1
+	tzspOptSNR      = 2
+	tzspOptDataRate = 3
+	tzspOptSensor   = 4
 )
 
-// Writer handles PCAP file output
+// Writer handles PCAP/PCAPNG file output
 type Writer struct {
 	filename     string
+	format       string
 	maxSizeMB    int
 	maxBackups   int
 	file         *os.File
-	writer       *pcapgo.Writer
+	writer       *pcapgo.Writer   // used when format == FormatPCAP
+	ngWriter     *pcapgo.NgWriter // used when format == FormatPCAPNG
+	ngIfaces     map[string]int   // TZSP protocol name -> pcapng interface ID
 	mu           sync.Mutex
+	buf          [4]byte
 	bytesWritten int64
 }
 
-// NewWriter creates a new PCAP writer
-func NewWriter(filename string, maxSizeMB, maxBackups int) (*Writer, error) {
+// NewWriter creates a new PCAP/PCAPNG writer. format selects the on-disk
+// layout; an empty format defaults to plain PCAP for backward compatibility.
+func NewWriter(filename string, maxSizeMB, maxBackups int, format string) (*Writer, error) {
+	if format == "" {
+		format = FormatPCAP
+	}
+	if format != FormatPCAP && format != FormatPCAPNG {
+		return nil, fmt.Errorf("unsupported PCAP output format: %q", format)
+	}
+
 	w := &Writer{
 		filename:   filename,
+		format:     format,
 		maxSizeMB:  maxSizeMB,
 		maxBackups: maxBackups,
 	}
@@ -37,26 +84,31 @@ func NewWriter(filename string, maxSizeMB, maxBackups int) (*Writer, error) {
 	return w, nil
 }
 
-// WritePacket writes a packet to the PCAP file
+// WritePacket writes a raw encapsulated packet, using the receive time as
+// its capture timestamp. It always writes to the default (Ethernet)
+// interface and carries no TZSP tag metadata; prefer WriteTZSPPacket when a
+// decoded tzsp.Packet is available.
 func (w *Writer) WritePacket(data []byte, timestamp time.Time) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	// Check if rotation is needed
-	if w.maxSizeMB > 0 && w.bytesWritten > int64(w.maxSizeMB)*1024*1024 {
-		if err := w.rotate(); err != nil {
-			return fmt.Errorf("failed to rotate file: %w", err)
+	if err := w.maybeRotate(len(data)); err != nil {
+		return err
+	}
+
+	if w.format == FormatPCAPNG {
+		if err := w.writeEnhancedPacketBlock(0, timestamp, data, nil); err != nil {
+			return fmt.Errorf("failed to write packet: %w", err)
 		}
+		w.bytesWritten += int64(len(data))
+		return nil
 	}
 
-	// Create capture info
 	ci := gopacket.CaptureInfo{
 		Timestamp:     timestamp,
 		CaptureLength: len(data),
 		Length:        len(data),
 	}
-
-	// Write packet
 	if err := w.writer.WritePacket(ci, data); err != nil {
 		return fmt.Errorf("failed to write packet: %w", err)
 	}
@@ -65,11 +117,228 @@ func (w *Writer) WritePacket(data []byte, timestamp time.Time) error {
 	return nil
 }
 
+// WriteTZSPPacket writes the encapsulated packet carried by a decoded TZSP
+// packet. In PCAPNG mode it routes the packet to an Interface Description
+// Block matching pkt.ProtocolName() and attaches the original RSSI/SNR/data
+// rate/sensor MAC as Enhanced Packet Block options, so the radio metadata
+// TZSP carries survives into the capture file. In plain PCAP mode this is
+// equivalent to WritePacket since PCAP has no place to put per-packet
+// metadata.
+func (w *Writer) WriteTZSPPacket(pkt *tzsp.Packet, receivedTime time.Time) error {
+	timestamp := receivedTime
+	if ts := pkt.GetTimestamp(); ts != nil {
+		timestamp = *ts
+	}
+
+	if w.format != FormatPCAPNG {
+		return w.WritePacket(pkt.EncapPacket, timestamp)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.maybeRotate(len(pkt.EncapPacket)); err != nil {
+		return err
+	}
+
+	ifaceID, err := w.interfaceForProtocol(pkt.ProtocolName())
+	if err != nil {
+		return fmt.Errorf("failed to resolve pcapng interface: %w", err)
+	}
+
+	if err := w.writeEnhancedPacketBlock(ifaceID, timestamp, pkt.EncapPacket, tzspTagOptions(pkt)); err != nil {
+		return fmt.Errorf("failed to write packet: %w", err)
+	}
+
+	w.bytesWritten += int64(len(pkt.EncapPacket))
+	return nil
+}
+
+// Rotate forces the current file to roll over immediately, regardless of
+// its size, e.g. in response to an operator's "rotate pcap" control
+// command.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+// maybeRotate rotates the file once the bytes already written exceed the
+// configured size limit; nextPacketLen is not counted. Caller must hold w.mu.
+func (w *Writer) maybeRotate(nextPacketLen int) error {
+	if w.maxSizeMB > 0 && w.bytesWritten > int64(w.maxSizeMB)*1024*1024 {
+		if err := w.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate file: %w", err)
+		}
+	}
+	return nil
+}
+
+// interfaceForProtocol returns the pcapng interface ID for a TZSP
+// encapsulated protocol name, adding a new Interface Description Block the
+// first time a protocol is seen. Caller must hold w.mu.
+func (w *Writer) interfaceForProtocol(protocolName string) (int, error) {
+	if id, ok := w.ngIfaces[protocolName]; ok {
+		return id, nil
+	}
+
+	id, err := w.ngWriter.AddInterface(pcapgo.NgInterface{
+		Name:                protocolName,
+		LinkType:            linkTypeForTZSPProtocol(protocolName),
+		TimestampResolution: 9,
+		SnapLength:          0,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	w.ngIfaces[protocolName] = id
+	return id, nil
+}
+
+// linkTypeForTZSPProtocol maps a tzsp.Packet.ProtocolName() value to the
+// pcapng link type of the interface that should carry it.
+func linkTypeForTZSPProtocol(protocolName string) layers.LinkType {
+	switch protocolName {
+	case "Ethernet":
+		return layers.LinkTypeEthernet
+	case "802.11":
+		return layers.LinkTypeIEEE802_11
+	case "IEEE 802.11 + RadioTap":
+		return layers.LinkTypeIEEE80211Radio
+	case "Prism":
+		return layers.LinkTypePrismHeader
+	default:
+		return layers.LinkTypeEthernet
+	}
+}
+
+// ngCustomOption is a single Enhanced Packet Block option to be written by
+// writeEnhancedPacketBlock.
+type ngCustomOption struct {
+	code  uint16
+	value []byte
+}
+
+// tzspTagOptions builds the opt_custom EPB option carrying the RSSI, SNR,
+// data rate and sensor MAC tags TZSP attached to the packet, if present.
+func tzspTagOptions(pkt *tzsp.Packet) []ngCustomOption {
+	var tlv []byte
+	appendTLV := func(subType byte, data []byte) {
+		tlv = append(tlv, subType, byte(len(data)))
+		tlv = append(tlv, data...)
+	}
+
+	if tag := pkt.GetTag(tzsp.TagRawRSSI); tag != nil {
+		appendTLV(tzspOptRSSI, tag.Data)
+	}
+	if tag := pkt.GetTag(tzsp.TagSNR); tag != nil {
+		appendTLV(tzspOptSNR, tag.Data)
+	}
+	if tag := pkt.GetTag(tzsp.TagDataRate); tag != nil {
+		appendTLV(tzspOptDataRate, tag.Data)
+	}
+	if tag := pkt.GetTag(tzsp.TagSensor); tag != nil {
+		appendTLV(tzspOptSensor, tag.Data)
+	}
+
+	if len(tlv) == 0 {
+		return nil
+	}
+
+	value := make([]byte, 4+len(tlv))
+	binary.LittleEndian.PutUint32(value[0:4], tzspPEN)
+	copy(value[4:], tlv)
+
+	return []ngCustomOption{{code: optCustomNoCopy, value: value}}
+}
+
+// writeEnhancedPacketBlock writes a raw PCAPNG Enhanced Packet Block,
+// including options. pcapgo.NgWriter.WritePacket doesn't expose a way to
+// attach per-packet options, so this writes directly to the underlying
+// file in the same wire format, after flushing anything pcapgo has
+// buffered so blocks stay in order. Caller must hold w.mu.
+func (w *Writer) writeEnhancedPacketBlock(ifaceID int, timestamp time.Time, data []byte, options []ngCustomOption) error {
+	if err := w.ngWriter.Flush(); err != nil {
+		return err
+	}
+
+	optionsLen := 0
+	for _, opt := range options {
+		optionsLen += 4 + padTo4(len(opt.value))
+	}
+	if len(options) > 0 {
+		optionsLen += 4 // opt_endofopt
+	}
+
+	dataPadded := padTo4(len(data))
+	blockLen := 4 + 4 + 4 + 4 + 4 + 4 + 4 + dataPadded + optionsLen + 4
+
+	header := make([]byte, 28)
+	binary.LittleEndian.PutUint32(header[0:4], ngBlockTypeEnhancedPacket)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(blockLen))
+	binary.LittleEndian.PutUint32(header[8:12], uint32(ifaceID))
+	ts := uint64(timestamp.UnixNano())
+	binary.LittleEndian.PutUint32(header[12:16], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(header[16:20], uint32(ts))
+	binary.LittleEndian.PutUint32(header[20:24], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(len(data)))
+
+	if _, err := w.file.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.file.Write(data); err != nil {
+		return err
+	}
+	if pad := dataPadded - len(data); pad > 0 {
+		if _, err := w.file.Write(make([]byte, pad)); err != nil {
+			return err
+		}
+	}
+
+	for _, opt := range options {
+		optHeader := make([]byte, 4)
+		binary.LittleEndian.PutUint16(optHeader[0:2], opt.code)
+		binary.LittleEndian.PutUint16(optHeader[2:4], uint16(len(opt.value)))
+		if _, err := w.file.Write(optHeader); err != nil {
+			return err
+		}
+		if _, err := w.file.Write(opt.value); err != nil {
+			return err
+		}
+		if pad := padTo4(len(opt.value)) - len(opt.value); pad > 0 {
+			if _, err := w.file.Write(make([]byte, pad)); err != nil {
+				return err
+			}
+		}
+	}
+	if len(options) > 0 {
+		if _, err := w.file.Write([]byte{optEndOfOpt, 0, 0, 0}); err != nil {
+			return err
+		}
+	}
+
+	binary.LittleEndian.PutUint32(w.buf[:], uint32(blockLen))
+	if _, err := w.file.Write(w.buf[:]); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func padTo4(n int) int {
+	return (n + 3) &^ 3
+}
+
 // Close closes the PCAP file
 func (w *Writer) Close() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if w.format == FormatPCAPNG && w.ngWriter != nil {
+		w.ngWriter.Flush()
+	}
+
 	if w.file != nil {
 		return w.file.Close()
 	}
@@ -80,6 +349,9 @@ func (w *Writer) Close() error {
 func (w *Writer) rotate() error {
 	// Close existing file
 	if w.file != nil {
+		if w.format == FormatPCAPNG && w.ngWriter != nil {
+			w.ngWriter.Flush()
+		}
 		w.file.Close()
 	}
 
@@ -110,16 +382,31 @@ func (w *Writer) rotate() error {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
 
-	// Create PCAP writer with Ethernet link type
+	w.file = f
+	w.bytesWritten = 0
+
+	if w.format == FormatPCAPNG {
+		ngWriter, err := pcapgo.NewNgWriterInterface(f, pcapgo.NgInterface{
+			Name:                "Ethernet",
+			LinkType:            layers.LinkTypeEthernet,
+			TimestampResolution: 9,
+			SnapLength:          0,
+		}, pcapgo.DefaultNgWriterOptions)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write PCAPNG header: %w", err)
+		}
+		w.ngWriter = ngWriter
+		w.ngIfaces = map[string]int{"Ethernet": 0}
+		return nil
+	}
+
 	writer := pcapgo.NewWriter(f)
 	if err := writer.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
 		f.Close()
 		return fmt.Errorf("failed to write PCAP header: %w", err)
 	}
-
-	w.file = f
 	w.writer = writer
-	w.bytesWritten = 0
 
 	return nil
 }
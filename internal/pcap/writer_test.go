@@ -0,0 +1,242 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pavelkim/tzsp_server/internal/tzsp"
+)
+
+func TestPadTo4(t *testing.T) {
+	tests := []struct{ in, want int }{
+		{0, 0}, {1, 4}, {2, 4}, {3, 4}, {4, 4}, {5, 8}, {8, 8},
+	}
+	for _, tt := range tests {
+		if got := padTo4(tt.in); got != tt.want {
+			t.Errorf("padTo4(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTzspTagOptionsNoTags(t *testing.T) {
+	pkt := &tzsp.Packet{}
+	if opts := tzspTagOptions(pkt); opts != nil {
+		t.Fatalf("expected nil options for a packet with no tags, got %+v", opts)
+	}
+}
+
+func TestTzspTagOptionsEncodesTLVInOrder(t *testing.T) {
+	pkt := &tzsp.Packet{
+		Tags: []tzsp.Tag{
+			{Type: tzsp.TagSensor, Data: []byte{1, 2, 3, 4, 5, 6}},
+			{Type: tzsp.TagRawRSSI, Data: []byte{0xE0}},
+			{Type: tzsp.TagSNR, Data: []byte{0x20}},
+			{Type: tzsp.TagDataRate, Data: []byte{0x0C}},
+		},
+	}
+
+	opts := tzspTagOptions(pkt)
+	if len(opts) != 1 {
+		t.Fatalf("expected exactly one opt_custom option, got %d", len(opts))
+	}
+	opt := opts[0]
+	if opt.code != optCustomNoCopy {
+		t.Fatalf("option code: got %d, want %d", opt.code, optCustomNoCopy)
+	}
+
+	value := opt.value
+	if len(value) < 4 {
+		t.Fatalf("option value too short for a PEN prefix: %d bytes", len(value))
+	}
+	if pen := binary.LittleEndian.Uint32(value[0:4]); pen != tzspPEN {
+		t.Fatalf("PEN: got %d, want %d", pen, tzspPEN)
+	}
+
+	// tzspTagOptions always checks RSSI, SNR, data rate, then sensor, in
+	// that fixed order, regardless of the order tags appear in pkt.Tags.
+	tlv := value[4:]
+	off := 0
+	readTLV := func() (subType, length byte, data []byte) {
+		t.Helper()
+		if off+2 > len(tlv) {
+			t.Fatalf("TLV truncated at offset %d", off)
+		}
+		subType, length = tlv[off], tlv[off+1]
+		off += 2
+		data = tlv[off : off+int(length)]
+		off += int(length)
+		return
+	}
+
+	if st, l, d := readTLV(); st != tzspOptRSSI || l != 1 || d[0] != 0xE0 {
+		t.Fatalf("RSSI TLV: got type=%d len=%d data=%v", st, l, d)
+	}
+	if st, l, d := readTLV(); st != tzspOptSNR || l != 1 || d[0] != 0x20 {
+		t.Fatalf("SNR TLV: got type=%d len=%d data=%v", st, l, d)
+	}
+	if st, l, d := readTLV(); st != tzspOptDataRate || l != 1 || d[0] != 0x0C {
+		t.Fatalf("DataRate TLV: got type=%d len=%d data=%v", st, l, d)
+	}
+	if st, l, d := readTLV(); st != tzspOptSensor || l != 6 || string(d) != "\x01\x02\x03\x04\x05\x06" {
+		t.Fatalf("Sensor TLV: got type=%d len=%d data=%v", st, l, d)
+	}
+	if off != len(tlv) {
+		t.Fatalf("unconsumed TLV bytes: %d left", len(tlv)-off)
+	}
+}
+
+func TestTzspTagOptionsPartialTags(t *testing.T) {
+	pkt := &tzsp.Packet{
+		Tags: []tzsp.Tag{
+			{Type: tzsp.TagSNR, Data: []byte{0x10}},
+		},
+	}
+	opts := tzspTagOptions(pkt)
+	if len(opts) != 1 {
+		t.Fatalf("expected one option, got %d", len(opts))
+	}
+	tlv := opts[0].value[4:]
+	if len(tlv) != 3 {
+		t.Fatalf("expected a single 3-byte TLV entry (only SNR present), got %d bytes", len(tlv))
+	}
+	if tlv[0] != tzspOptSNR || tlv[1] != 1 || tlv[2] != 0x10 {
+		t.Fatalf("unexpected TLV: %v", tlv)
+	}
+}
+
+// readEPB parses a single raw Enhanced Packet Block starting at data[0],
+// mirroring the wire format writeEnhancedPacketBlock produces, and returns
+// its fixed fields, packet data and raw (unparsed) options bytes.
+func readEPB(t *testing.T, data []byte) (ifaceID int, capLen int, pktData []byte, optionsAndTrailer []byte) {
+	t.Helper()
+	if len(data) < 28 {
+		t.Fatalf("block too short for an EPB header: %d bytes", len(data))
+	}
+	blockType := binary.LittleEndian.Uint32(data[0:4])
+	if blockType != ngBlockTypeEnhancedPacket {
+		t.Fatalf("block type: got %#x, want %#x", blockType, ngBlockTypeEnhancedPacket)
+	}
+	blockLen := binary.LittleEndian.Uint32(data[4:8])
+	ifaceID = int(binary.LittleEndian.Uint32(data[8:12]))
+	capLen = int(binary.LittleEndian.Uint32(data[20:24]))
+	origLen := int(binary.LittleEndian.Uint32(data[24:28]))
+	if capLen != origLen {
+		t.Fatalf("captured length %d != original length %d", capLen, origLen)
+	}
+
+	pktData = data[28 : 28+capLen]
+	rest := data[28+padTo4(capLen):]
+
+	trailerLen := binary.LittleEndian.Uint32(rest[len(rest)-4:])
+	if trailerLen != blockLen {
+		t.Fatalf("trailing block length %d != leading block length %d", trailerLen, blockLen)
+	}
+
+	return ifaceID, capLen, pktData, rest
+}
+
+func TestWriteTZSPPacketPCAPNGRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "capture.pcapng")
+
+	w, err := NewWriter(path, 0, 0, FormatPCAPNG)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+
+	pkt := &tzsp.Packet{
+		Protocol:     0x01, // Ethernet, per tzsp.Packet.ProtocolName
+		EncapPacket:  []byte{0xDE, 0xAD, 0xBE, 0xEF, 0x01},
+		ReceivedTime: time.Unix(1700000000, 0),
+		Tags: []tzsp.Tag{
+			{Type: tzsp.TagRawRSSI, Data: []byte{0xE0}},
+		},
+	}
+
+	if err := w.WriteTZSPPacket(pkt, pkt.ReceivedTime); err != nil {
+		t.Fatalf("WriteTZSPPacket: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	// The file starts with pcapgo's Section Header Block + Interface
+	// Description Block; find our Enhanced Packet Block by scanning for
+	// its block type, since pcapgo doesn't expose the header sizes.
+	epbOff := -1
+	for i := 0; i+4 <= len(raw); i++ {
+		if binary.LittleEndian.Uint32(raw[i:i+4]) == ngBlockTypeEnhancedPacket {
+			epbOff = i
+			break
+		}
+	}
+	if epbOff < 0 {
+		t.Fatalf("no Enhanced Packet Block found in %d bytes", len(raw))
+	}
+
+	_, capLen, pktData, optsAndTrailer := readEPB(t, raw[epbOff:])
+	if capLen != len(pkt.EncapPacket) {
+		t.Fatalf("captured length: got %d, want %d", capLen, len(pkt.EncapPacket))
+	}
+	if string(pktData) != string(pkt.EncapPacket) {
+		t.Fatalf("packet data: got %x, want %x", pktData, pkt.EncapPacket)
+	}
+
+	// optsAndTrailer is [opt_custom header+value+pad][opt_endofopt][block length].
+	if len(optsAndTrailer) < 4+4 {
+		t.Fatalf("options+trailer too short: %d bytes", len(optsAndTrailer))
+	}
+	optCode := binary.LittleEndian.Uint16(optsAndTrailer[0:2])
+	optLen := binary.LittleEndian.Uint16(optsAndTrailer[2:4])
+	if optCode != optCustomNoCopy {
+		t.Fatalf("option code: got %d, want %d", optCode, optCustomNoCopy)
+	}
+
+	optValue := optsAndTrailer[4 : 4+optLen]
+	if pen := binary.LittleEndian.Uint32(optValue[0:4]); pen != tzspPEN {
+		t.Fatalf("option PEN: got %d, want %d", pen, tzspPEN)
+	}
+	tlv := optValue[4:]
+	if tlv[0] != tzspOptRSSI || tlv[1] != 1 || tlv[2] != 0xE0 {
+		t.Fatalf("unexpected RSSI TLV in on-disk option: %v", tlv)
+	}
+
+	afterOpt := 4 + padTo4(int(optLen))
+	endOfOptCode := binary.LittleEndian.Uint16(optsAndTrailer[afterOpt : afterOpt+2])
+	if endOfOptCode != optEndOfOpt {
+		t.Fatalf("expected opt_endofopt after opt_custom, got code %d", endOfOptCode)
+	}
+}
+
+func TestWritePacketPlainPCAPHasNoOptions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "capture.pcap")
+
+	w, err := NewWriter(path, 0, 0, FormatPCAP)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	data := []byte{1, 2, 3, 4}
+	if err := w.WritePacket(data, time.Now()); err != nil {
+		t.Fatalf("WritePacket: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size() == 0 {
+		t.Fatalf("expected a non-empty PCAP file")
+	}
+}
@@ -1,43 +1,57 @@
 package qingping
 
 import (
-	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"strings"
-	"time"
 
 	"github.com/pavelkim/tzsp_server/internal/decoder"
 	"github.com/pavelkim/tzsp_server/internal/logger"
+	"github.com/pavelkim/tzsp_server/internal/mqtt"
 )
 
-// Filter defines packet filtering criteria
-type Filter struct {
-	SrcIP    string
-	DstIP    string
-	DstPort  uint16
-	Protocol string // tcp, udp, icmp
+// Sink type identifiers accepted by SinkConfig.Type.
+const (
+	SinkTypeHTTP  = "http"
+	SinkTypeMQTT  = "mqtt"
+	SinkTypeFile  = "file"
+	SinkTypeKafka = "kafka"
+)
+
+// SinkConfig configures one Sink an Exporter fans out to. Type selects
+// which of HTTP/MQTT/File/Kafka is built; the other fields are ignored.
+type SinkConfig struct {
+	// Name identifies this sink in logs and GetStats; defaults to Type
+	// if empty.
+	Name string
+	Type string
+
+	// QueueSize bounds how many pending Publish calls this sink buffers
+	// before Backpressure applies. <= 0 uses defaultSinkQueueSize.
+	QueueSize int
+	// Backpressure is BackpressureBlock (default) or
+	// BackpressureDropOldest.
+	Backpressure BackpressurePolicy
+
+	HTTP  HTTPSinkConfig
+	MQTT  MQTTSinkConfig
+	File  FileSinkConfig
+	Kafka KafkaSinkConfig
 }
 
 // Config holds the QingPing exporter configuration
 type Config struct {
-	Enabled          bool
-	Filter           Filter
-	StrictJSON       bool // If true, invalid JSON will fail packet processing
-	UpstreamURL      string
-	IgnoreSSL        bool
-	IgnoreHTTPErrors bool // If true, non-2xx responses won't be logged as errors
-	Logger           *logger.Logger
+	Enabled    bool
+	StrictJSON bool // If true, invalid JSON will fail packet processing
+	Sinks      []SinkConfig
+	Logger     *logger.Logger
 }
 
 // Exporter handles QingPing sensor data extraction and forwarding
 type Exporter struct {
-	config     Config
-	httpClient *http.Client
-	logger     *logger.Logger
+	config      Config
+	logger      *logger.Logger
+	mqttDecoder *mqtt.Decoder
+	sinks       []*sinkHandle
 }
 
 // NewExporter creates a new QingPing exporter
@@ -46,154 +60,83 @@ func NewExporter(config Config) (*Exporter, error) {
 		return nil, nil
 	}
 
-	if config.UpstreamURL == "" {
-		return nil, fmt.Errorf("upstream URL is required")
-	}
-
-	// Create HTTP client with optional SSL verification skip
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: config.IgnoreSSL,
-		},
-		MaxIdleConns:       10,
-		IdleConnTimeout:    30 * time.Second,
-		DisableCompression: false,
+	if len(config.Sinks) == 0 {
+		return nil, fmt.Errorf("at least one sink is required")
 	}
 
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   10 * time.Second,
+	sinks := make([]*sinkHandle, 0, len(config.Sinks))
+	for _, sc := range config.Sinks {
+		handle, err := buildSinkHandle(sc, config.Logger)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, fmt.Errorf("sink %q: %w", sinkName(sc), err)
+		}
+		sinks = append(sinks, handle)
 	}
 
 	e := &Exporter{
-		config:     config,
-		httpClient: client,
-		logger:     config.Logger,
+		config:      config,
+		logger:      config.Logger,
+		mqttDecoder: mqtt.NewDecoder(),
+		sinks:       sinks,
 	}
 
 	e.logger.Info("QingPing exporter initialized",
-		"upstream_url", config.UpstreamURL,
 		"strict_json", config.StrictJSON,
-		"ignore_ssl", config.IgnoreSSL,
-		"ignore_http_errors", config.IgnoreHTTPErrors)
-	e.logger.Info("QingPing filter settings",
-		"src_ip", config.Filter.SrcIP,
-		"dst_ip", config.Filter.DstIP,
-		"dst_port", config.Filter.DstPort,
-		"protocol", config.Filter.Protocol)
+		"sinks", len(sinks))
 
 	return e, nil
 }
 
-// matchesFilter checks if a packet matches the configured filter criteria
-func (e *Exporter) matchesFilter(pkt *decoder.PacketInfo) bool {
-	// Check source IP if configured
-	if e.config.Filter.SrcIP != "" {
-		if pkt.SrcIP != e.config.Filter.SrcIP {
-			return false
-		}
-	}
-
-	// Check destination IP if configured
-	if e.config.Filter.DstIP != "" {
-		if pkt.DstIP != e.config.Filter.DstIP {
-			return false
-		}
+// sinkName returns sc.Name, or sc.Type if Name is unset.
+func sinkName(sc SinkConfig) string {
+	if sc.Name != "" {
+		return sc.Name
 	}
+	return sc.Type
+}
 
-	// Check destination port if configured
-	if e.config.Filter.DstPort != 0 {
-		if pkt.DstPort != e.config.Filter.DstPort {
-			return false
-		}
+// buildSinkHandle constructs the Sink implementation for sc.Type and
+// wraps it in a sinkHandle.
+func buildSinkHandle(sc SinkConfig, log *logger.Logger) (*sinkHandle, error) {
+	var sink Sink
+	var err error
+
+	switch sc.Type {
+	case SinkTypeHTTP:
+		sink, err = NewHTTPSink(sc.HTTP)
+	case SinkTypeMQTT:
+		sink, err = NewMQTTSink(sc.MQTT)
+	case SinkTypeFile:
+		sink, err = NewFileSink(sc.File)
+	case SinkTypeKafka:
+		sink, err = NewKafkaSink(sc.Kafka)
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", sc.Type)
 	}
-
-	// Check protocol if configured
-	if e.config.Filter.Protocol != "" {
-		proto := strings.ToLower(e.config.Filter.Protocol)
-		pktProto := strings.ToLower(pkt.Protocol)
-		if proto != pktProto {
-			return false
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	return true
+	return newSinkHandle(sinkName(sc), sink, sc.QueueSize, sc.Backpressure, log), nil
 }
 
-// extractJSON extracts JSON payload from MQTT or raw packet data
-// The QingPing device uses MQTT protocol with length-prefixed strings:
-// Format: [control_byte][remaining_length][topic_length_msb][topic_length_lsb][topic_string][payload_length_msb][payload_length_lsb][json_payload]
-// This function properly parses the MQTT PUBLISH packet structure
-func (e *Exporter) extractJSON(payload []byte) (jsonData []byte, mqttTopic string, err error) {
-	// First, try to locate the MQTT topic (starts with '/') to know where to search for JSON
-	// This prevents finding '{' bytes in MQTT protocol headers
-	searchStartOffset := 0
-	topicStart := -1
-	topicEnd := -1
-
-	// Look for MQTT topic pattern: '/' followed by printable characters
-	firstSlash := bytes.IndexByte(payload, '/')
-	if firstSlash != -1 && firstSlash >= 2 {
-		// Try to parse MQTT string with length prefix
-		lengthMSB := int(payload[firstSlash-2])
-		lengthLSB := int(payload[firstSlash-1])
-		topicLength := (lengthMSB << 8) | lengthLSB
-
-		// Validate the length seems reasonable
-		if topicLength > 0 && topicLength < 256 && firstSlash+topicLength < len(payload) {
-			// Verify all bytes in the declared topic are printable ASCII
-			allPrintable := true
-			for j := firstSlash; j < firstSlash+topicLength && j < len(payload); j++ {
-				if payload[j] < 0x20 || payload[j] > 0x7E {
-					allPrintable = false
-					break
-				}
-			}
-
-			if allPrintable {
-				topicStart = firstSlash
-				topicEnd = firstSlash + topicLength
-				mqttTopic = string(payload[topicStart:topicEnd])
-				// Start searching for JSON after the topic
-				searchStartOffset = topicEnd
-			}
-		}
-
-		// Fallback: if length-based parsing failed, find topic end by scanning for non-printable chars
-		if topicStart == -1 {
-			topicStart = firstSlash
-			topicEnd = firstSlash
-			for i := firstSlash; i < len(payload); i++ {
-				if payload[i] < 0x20 || payload[i] > 0x7E {
-					topicEnd = i
-					break
-				}
-			}
-			if topicEnd > topicStart {
-				mqttTopic = string(payload[topicStart:topicEnd])
-				searchStartOffset = topicEnd
-			}
-		}
-	}
-
-	// Find the first '{' character AFTER the topic (or from start if no topic found)
-	// This avoids picking up '{' bytes in MQTT protocol headers
-	jsonStart := bytes.IndexByte(payload[searchStartOffset:], '{')
-	if jsonStart == -1 {
-		return nil, mqttTopic, fmt.Errorf("no JSON data found in payload")
-	}
-	jsonStart += searchStartOffset // Adjust to absolute offset
+// flowKey identifies the directional TCP flow a packet belongs to, the
+// unit the MQTT decoder buffers reassembly state per.
+func flowKey(pkt *decoder.PacketInfo) string {
+	return fmt.Sprintf("%s:%d-%s:%d", pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort)
+}
 
-	// Find the last '}' character which marks the end of JSON
-	jsonEnd := bytes.LastIndexByte(payload, '}')
-	if jsonEnd == -1 || jsonEnd <= jsonStart {
-		return nil, mqttTopic, fmt.Errorf("incomplete JSON data in payload")
+// connKey identifies the underlying connection regardless of direction,
+// so a CONNECT seen going one way and a PUBLISH seen going the other are
+// recognized as the same MQTT session.
+func connKey(pkt *decoder.PacketInfo) string {
+	a := fmt.Sprintf("%s:%d", pkt.SrcIP, pkt.SrcPort)
+	b := fmt.Sprintf("%s:%d", pkt.DstIP, pkt.DstPort)
+	if a < b {
+		return a + "-" + b
 	}
-
-	// Extract JSON portion (inclusive of braces)
-	jsonData = payload[jsonStart : jsonEnd+1]
-
-	return jsonData, mqttTopic, nil
+	return b + "-" + a
 }
 
 // validateJSON checks if the payload is valid JSON
@@ -205,32 +148,21 @@ func (e *Exporter) validateJSON(payload []byte) (bool, error) {
 	return true, nil
 }
 
-// Export processes a packet and forwards sensor data if it matches criteria
+// Export processes a packet and forwards sensor data upstream. Callers are
+// expected to have already applied any configured bpf_filter (see
+// internal/filter) before invoking Export.
 func (e *Exporter) Export(pkt *decoder.PacketInfo) error {
-	// Check if packet matches filter
-	if !e.matchesFilter(pkt) {
-		e.logger.Debug("QingPing packet does not match filter criteria",
-			"timestamp", pkt.Timestamp,
-			"src_ip", pkt.SrcIP,
-			"src_port", pkt.SrcPort,
-			"dst_ip", pkt.DstIP,
-			"dst_port", pkt.DstPort,
-			"protocol", pkt.Protocol,
-			"outcome", "skipped")
-		return nil
-	}
-
-	e.logger.Debug("QingPing filter matched",
+	e.logger.Debug("QingPing packet accepted for processing",
 		"timestamp", pkt.Timestamp,
 		"src_ip", pkt.SrcIP,
 		"src_port", pkt.SrcPort,
 		"dst_ip", pkt.DstIP,
 		"dst_port", pkt.DstPort,
 		"protocol", pkt.Protocol,
-		"payload_size", len(pkt.PacketData))
+		"payload_size", len(pkt.Payload))
 
 	// No payload to process
-	if len(pkt.PacketData) == 0 {
+	if len(pkt.Payload) == 0 {
 		e.logger.Warn("QingPing packet processing failed: no payload",
 			"timestamp", pkt.Timestamp,
 			"src_ip", pkt.SrcIP,
@@ -242,52 +174,50 @@ func (e *Exporter) Export(pkt *decoder.PacketInfo) error {
 		return nil
 	}
 
-	// Extract JSON from MQTT payload
-	jsonData, mqttTopic, err := e.extractJSON(pkt.PacketData)
+	messages, err := e.mqttDecoder.Feed(flowKey(pkt), connKey(pkt), pkt.Payload)
 	if err != nil {
-		if e.config.StrictJSON {
-			e.logger.Error("QingPing packet processing failed: JSON extraction error (strict mode)",
-				"timestamp", pkt.Timestamp,
-				"src_ip", pkt.SrcIP,
-				"src_port", pkt.SrcPort,
-				"dst_ip", pkt.DstIP,
-				"dst_port", pkt.DstPort,
-				"protocol", pkt.Protocol,
-				"packet_len", pkt.Length,
-				"payload_len", len(pkt.PacketData),
-				"error", err,
-				"payload_preview", string(pkt.PacketData[:min(100, len(pkt.PacketData))]),
-				"outcome", "failed_extraction_strict")
-			return fmt.Errorf("JSON extraction failed: %v", err)
-		}
-		e.logger.Warn("QingPing packet processing skipped: JSON extraction error (lenient mode)",
+		e.logger.Warn("QingPing MQTT stream desynchronized, dropping buffered state",
 			"timestamp", pkt.Timestamp,
 			"src_ip", pkt.SrcIP,
 			"src_port", pkt.SrcPort,
 			"dst_ip", pkt.DstIP,
 			"dst_port", pkt.DstPort,
-			"protocol", pkt.Protocol,
-			"packet_len", pkt.Length,
-			"payload_len", len(pkt.PacketData),
 			"error", err,
-			"payload_preview", string(pkt.PacketData[:min(100, len(pkt.PacketData))]),
-			"outcome", "failed_extraction_lenient")
+			"outcome", "failed_mqtt_decode")
 		return nil
 	}
 
-	logFields := []interface{}{
-		"src_ip", pkt.SrcIP,
-		"dst_ip", pkt.DstIP,
-		"json_size", len(jsonData),
-		"total_payload_size", len(pkt.PacketData),
+	if len(messages) == 0 {
+		// Segment didn't complete a PUBLISH (e.g. it was a CONNECT, or
+		// the PUBLISH spans further segments still to arrive).
+		e.logger.Debug("QingPing packet buffered, no complete MQTT PUBLISH yet",
+			"src_ip", pkt.SrcIP,
+			"dst_ip", pkt.DstIP)
+		return nil
 	}
-	if mqttTopic != "" {
-		logFields = append(logFields, "mqtt_topic", mqttTopic)
+
+	for _, msg := range messages {
+		if err := e.processPublish(pkt, msg); err != nil {
+			return err
+		}
 	}
-	e.logger.Debug("QingPing extracted JSON from MQTT payload", logFields...)
 
-	// Validate JSON
-	valid, err := e.validateJSON(jsonData)
+	return nil
+}
+
+// processPublish validates a single decoded MQTT PUBLISH message and fans
+// it out to every configured sink.
+func (e *Exporter) processPublish(pkt *decoder.PacketInfo, msg *mqtt.Message) error {
+	e.logger.Debug("QingPing decoded MQTT PUBLISH",
+		"src_ip", pkt.SrcIP,
+		"dst_ip", pkt.DstIP,
+		"mqtt_topic", msg.Topic,
+		"mqtt_qos", msg.QoS,
+		"mqtt_retain", msg.Retain,
+		"mqtt_dup", msg.Dup,
+		"json_size", len(msg.Payload))
+
+	valid, err := e.validateJSON(msg.Payload)
 	if !valid {
 		if e.config.StrictJSON {
 			e.logger.Error("QingPing packet processing failed: JSON validation error (strict mode)",
@@ -299,11 +229,10 @@ func (e *Exporter) Export(pkt *decoder.PacketInfo) error {
 				"protocol", pkt.Protocol,
 				"packet_len", pkt.Length,
 				"error", err,
-				"json_preview", string(jsonData[:min(100, len(jsonData))]),
+				"json_preview", string(msg.Payload[:min(100, len(msg.Payload))]),
 				"outcome", "failed_validation_strict")
 			return fmt.Errorf("strict JSON validation failed: %v", err)
 		}
-		// Continue processing even with invalid JSON if not strict
 		e.logger.Warn("QingPing JSON validation failed but continuing (lenient mode)",
 			"timestamp", pkt.Timestamp,
 			"src_ip", pkt.SrcIP,
@@ -313,48 +242,28 @@ func (e *Exporter) Export(pkt *decoder.PacketInfo) error {
 			"protocol", pkt.Protocol,
 			"packet_len", pkt.Length,
 			"error", err,
-			"json_preview", string(jsonData[:min(100, len(jsonData))]),
+			"json_preview", string(msg.Payload[:min(100, len(msg.Payload))]),
 			"outcome", "validation_failed_continuing")
 	} else {
 		e.logger.Debug("QingPing JSON validation passed",
 			"src_ip", pkt.SrcIP,
 			"dst_ip", pkt.DstIP,
-			"json_size", len(jsonData))
+			"json_size", len(msg.Payload))
 	}
 
-	// Submit to upstream server (with extracted JSON)
-	if err := e.submitToUpstream(pkt, jsonData, mqttTopic); err != nil {
-		if e.config.IgnoreHTTPErrors {
-			e.logger.Warn("QingPing packet processed but upstream submit failed (ignored)",
-				"timestamp", pkt.Timestamp,
-				"src_ip", pkt.SrcIP,
-				"src_port", pkt.SrcPort,
-				"dst_ip", pkt.DstIP,
-				"dst_port", pkt.DstPort,
-				"protocol", pkt.Protocol,
-				"packet_len", pkt.Length,
-				"payload_len", len(pkt.PacketData),
-				"upstream_url", e.config.UpstreamURL,
-				"json_size", len(jsonData),
-				"error", err,
-				"outcome", "upstream_failed_ignored")
-			return nil
-		}
-		e.logger.Error("QingPing packet processing failed: upstream submit error",
-			"timestamp", pkt.Timestamp,
-			"src_ip", pkt.SrcIP,
-			"src_port", pkt.SrcPort,
-			"dst_ip", pkt.DstIP,
-			"dst_port", pkt.DstPort,
-			"protocol", pkt.Protocol,
-			"packet_len", pkt.Length,
-			"payload_len", len(pkt.PacketData),
-			"upstream_url", e.config.UpstreamURL,
-			"json_size", len(jsonData),
-			"error", err,
-			"outcome", "failed_upstream")
-		return fmt.Errorf("failed to submit to upstream: %v", err)
+	meta := PacketMeta{
+		Timestamp:  pkt.Timestamp,
+		SrcIP:      pkt.SrcIP,
+		SrcPort:    pkt.SrcPort,
+		DstIP:      pkt.DstIP,
+		DstPort:    pkt.DstPort,
+		Protocol:   pkt.Protocol,
+		MQTTTopic:  msg.Topic,
+		MQTTQoS:    msg.QoS,
+		MQTTRetain: msg.Retain,
+		MQTTDup:    msg.Dup,
 	}
+	e.fanOut(msg.Topic, msg.Payload, meta)
 
 	e.logger.Info("QingPing packet processed successfully",
 		"timestamp", pkt.Timestamp,
@@ -363,60 +272,70 @@ func (e *Exporter) Export(pkt *decoder.PacketInfo) error {
 		"dst_ip", pkt.DstIP,
 		"dst_port", pkt.DstPort,
 		"protocol", pkt.Protocol,
-		"upstream_url", e.config.UpstreamURL,
-		"json_size", len(jsonData),
-		"outcome", "success")
+		"json_size", len(msg.Payload),
+		"outcome", "queued")
 
 	return nil
 }
 
-// submitToUpstream sends the extracted JSON payload to the upstream server via HTTP POST
-func (e *Exporter) submitToUpstream(pkt *decoder.PacketInfo, jsonData []byte, mqttTopic string) error {
-	// Prepare the request with extracted JSON data
-	req, err := http.NewRequest("POST", e.config.UpstreamURL, bytes.NewReader(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %v", err)
+// ExportHTTPMessage forwards a reassembled HTTP request body recovered by
+// internal/reassembly to every configured sink, the same way a
+// single-packet MQTT payload would be. This lets sensors that POST JSON
+// bodies split across TCP segments be forwarded without truncation.
+func (e *Exporter) ExportHTTPMessage(flowKey, method, url string, body []byte) error {
+	if len(body) == 0 {
+		e.logger.Warn("QingPing HTTP message processing failed: empty body",
+			"flow", flowKey,
+			"method", method,
+			"url", url,
+			"outcome", "failed_empty_body")
+		return nil
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "tzsp-qingping-exporter/1.0")
-
-	// Add custom headers with packet metadata
-	if pkt.SrcIP != "" {
-		req.Header.Set("X-Source-IP", pkt.SrcIP)
-	}
-	if pkt.DstIP != "" {
-		req.Header.Set("X-Destination-IP", pkt.DstIP)
+	valid, err := e.validateJSON(body)
+	if !valid {
+		if e.config.StrictJSON {
+			e.logger.Error("QingPing HTTP message processing failed: JSON validation error (strict mode)",
+				"flow", flowKey,
+				"method", method,
+				"url", url,
+				"error", err,
+				"outcome", "failed_validation_strict")
+			return fmt.Errorf("strict JSON validation failed: %v", err)
+		}
+		e.logger.Warn("QingPing HTTP message JSON validation failed but continuing (lenient mode)",
+			"flow", flowKey,
+			"method", method,
+			"url", url,
+			"error", err,
+			"outcome", "validation_failed_continuing")
 	}
-	req.Header.Set("X-Destination-Port", fmt.Sprintf("%d", pkt.DstPort))
-	req.Header.Set("X-Protocol", pkt.Protocol)
-	req.Header.Set("X-Timestamp", fmt.Sprintf("%d", pkt.Timestamp))
 
-	// Add MQTT topic if extracted
-	if mqttTopic != "" {
-		req.Header.Set("X-MQTT-Topic", mqttTopic)
+	meta := PacketMeta{
+		ReassembledFlow: flowKey,
+		HTTPMethod:      method,
+		HTTPURL:         url,
 	}
+	e.fanOut("", body, meta)
 
-	// Send the request
-	resp, err := e.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("HTTP request failed: %v", err)
-	}
-	defer resp.Body.Close()
+	e.logger.Info("QingPing reassembled HTTP message processed successfully",
+		"flow", flowKey,
+		"method", method,
+		"url", url,
+		"body_size", len(body),
+		"outcome", "queued")
 
-	// Read response body
-	body, _ := io.ReadAll(resp.Body)
+	return nil
+}
 
-	// Check response status
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("upstream returned non-OK status: %d, body: %s", resp.StatusCode, string(body))
+// fanOut queues (topic, payload, meta) onto every configured sink's own
+// bounded queue; each sink's worker goroutine and backpressure policy
+// (see sinkHandle) decide how/when it's actually delivered.
+func (e *Exporter) fanOut(topic string, payload []byte, meta PacketMeta) {
+	job := sinkJob{topic: topic, payload: payload, meta: meta}
+	for _, h := range e.sinks {
+		h.enqueue(job)
 	}
-
-	e.logger.Debug("QingPing upstream response",
-		"status_code", resp.StatusCode,
-		"response_body", string(body))
-
-	return nil
 }
 
 // Close cleans up the exporter resources
@@ -424,7 +343,7 @@ func (e *Exporter) Close() error {
 	if e == nil {
 		return nil
 	}
-	e.httpClient.CloseIdleConnections()
+	closeSinks(e.sinks)
 	e.logger.Info("QingPing exporter closed")
 	return nil
 }
@@ -437,14 +356,19 @@ func min(a, b int) int {
 	return b
 }
 
-// GetStats returns statistics about the exporter (placeholder for future implementation)
+// GetStats returns per-sink success/failure/dropped counters and queue
+// depth, keyed by sink name.
 func (e *Exporter) GetStats() map[string]interface{} {
 	if e == nil {
 		return nil
 	}
+	sinks := make(map[string]interface{}, len(e.sinks))
+	for _, h := range e.sinks {
+		sinks[h.name] = h.stats()
+	}
 	return map[string]interface{}{
-		"enabled":      e.config.Enabled,
-		"upstream_url": e.config.UpstreamURL,
-		"strict_json":  e.config.StrictJSON,
+		"enabled":     e.config.Enabled,
+		"strict_json": e.config.StrictJSON,
+		"sinks":       sinks,
 	}
 }
@@ -0,0 +1,178 @@
+package qingping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileSinkConfig configures a FileSink.
+type FileSinkConfig struct {
+	OutputFile string
+	// MaxSizeMB rotates the file once it exceeds this size; 0 disables
+	// size-based rotation.
+	MaxSizeMB int
+	// MaxBackups bounds how many rotated files are kept (OutputFile.1,
+	// .2, ...); 0 disables rotation (the file grows unbounded).
+	MaxBackups int
+}
+
+// fileSinkRecord is one JSON-lines record written by FileSink.
+type fileSinkRecord struct {
+	Timestamp       int64           `json:"timestamp"`
+	SrcIP           string          `json:"src_ip,omitempty"`
+	SrcPort         uint16          `json:"src_port,omitempty"`
+	DstIP           string          `json:"dst_ip,omitempty"`
+	DstPort         uint16          `json:"dst_port,omitempty"`
+	Protocol        string          `json:"protocol,omitempty"`
+	Topic           string          `json:"mqtt_topic,omitempty"`
+	MQTTQoS         uint8           `json:"mqtt_qos,omitempty"`
+	MQTTRetain      bool            `json:"mqtt_retain,omitempty"`
+	MQTTDup         bool            `json:"mqtt_dup,omitempty"`
+	ReassembledFlow string          `json:"reassembled_flow,omitempty"`
+	HTTPMethod      string          `json:"http_method,omitempty"`
+	HTTPURL         string          `json:"http_url,omitempty"`
+	Payload         json.RawMessage `json:"payload"`
+}
+
+// FileSink appends one JSON object per line to a rolling file, the same
+// size/backup-count rotation scheme internal/pcap.Writer uses for capture
+// files.
+type FileSink struct {
+	config FileSinkConfig
+
+	mu           sync.Mutex
+	file         *os.File
+	bytesWritten int64
+}
+
+// NewFileSink creates a FileSink appending to cfg.OutputFile, creating it
+// if necessary.
+func NewFileSink(cfg FileSinkConfig) (*FileSink, error) {
+	if cfg.OutputFile == "" {
+		return nil, fmt.Errorf("output file is required")
+	}
+
+	s := &FileSink{config: cfg}
+	f, size, err := openAppend(cfg.OutputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open QingPing file sink: %w", err)
+	}
+	s.file = f
+	s.bytesWritten = size
+	return s, nil
+}
+
+// openAppend opens path for append, creating it if needed, and returns
+// its current size so rotation thresholds measured across restarts.
+func openAppend(path string) (*os.File, int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+// Publish marshals payload and meta as one JSON line and appends it to
+// the sink's file, rotating first if the file has grown past MaxSizeMB.
+func (s *FileSink) Publish(ctx context.Context, topic string, payload []byte, meta PacketMeta) error {
+	record := fileSinkRecord{
+		Timestamp:       meta.Timestamp,
+		SrcIP:           meta.SrcIP,
+		SrcPort:         meta.SrcPort,
+		DstIP:           meta.DstIP,
+		DstPort:         meta.DstPort,
+		Protocol:        meta.Protocol,
+		Topic:           topic,
+		MQTTQoS:         meta.MQTTQoS,
+		MQTTRetain:      meta.MQTTRetain,
+		MQTTDup:         meta.MQTTDup,
+		ReassembledFlow: meta.ReassembledFlow,
+		HTTPMethod:      meta.HTTPMethod,
+		HTTPURL:         meta.HTTPURL,
+		Payload:         json.RawMessage(payload),
+	}
+	if !json.Valid(payload) {
+		// StrictJSON (if configured) already rejected this upstream; in
+		// lenient mode, fall back to a quoted string so the line stays
+		// valid JSON.
+		quoted, err := json.Marshal(string(payload))
+		if err != nil {
+			return fmt.Errorf("failed to encode non-JSON payload: %w", err)
+		}
+		record.Payload = json.RawMessage(quoted)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal file sink record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MaxSizeMB > 0 && s.bytesWritten > int64(s.config.MaxSizeMB)*1024*1024 {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate QingPing file sink: %w", err)
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.bytesWritten += int64(n)
+	return err
+}
+
+// rotate closes the current file, shifts backups, and opens a fresh file
+// at s.config.OutputFile. Caller must hold s.mu.
+func (s *FileSink) rotate() error {
+	s.file.Close()
+
+	if s.config.MaxBackups > 0 {
+		for i := s.config.MaxBackups - 1; i >= 0; i-- {
+			oldName := s.backupName(i)
+			newName := s.backupName(i + 1)
+			if _, err := os.Stat(oldName); err == nil {
+				if i == s.config.MaxBackups-1 {
+					os.Remove(oldName)
+				} else {
+					os.Rename(oldName, newName)
+				}
+			}
+		}
+		if _, err := os.Stat(s.config.OutputFile); err == nil {
+			os.Rename(s.config.OutputFile, s.backupName(0))
+		}
+	}
+
+	f, _, err := openAppend(s.config.OutputFile)
+	if err != nil {
+		return err
+	}
+	s.file = f
+	s.bytesWritten = 0
+	return nil
+}
+
+// backupName returns the rotated filename at index (0 is the most recent
+// backup), matching internal/pcap.Writer's naming scheme.
+func (s *FileSink) backupName(index int) string {
+	if index == 0 {
+		return s.config.OutputFile + ".1"
+	}
+	return fmt.Sprintf("%s.%d", s.config.OutputFile, index+1)
+}
+
+// Close flushes and closes the sink's file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
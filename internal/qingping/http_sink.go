@@ -0,0 +1,111 @@
+package qingping
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPSinkConfig configures an HTTPSink.
+type HTTPSinkConfig struct {
+	UpstreamURL string
+	IgnoreSSL   bool
+	// IgnoreHTTPErrors, if true, makes Publish return nil instead of an
+	// error for a failed request or non-2xx response (the failure is
+	// still counted via the sink's own success/failure stats).
+	IgnoreHTTPErrors bool
+}
+
+// HTTPSink POSTs each message's payload to a fixed upstream URL, carrying
+// packet/MQTT metadata as request headers. This is the original QingPing
+// export behavior, now built only for a sink whose Type is "http".
+type HTTPSink struct {
+	config HTTPSinkConfig
+	client *http.Client
+}
+
+// NewHTTPSink creates an HTTPSink posting to cfg.UpstreamURL.
+func NewHTTPSink(cfg HTTPSinkConfig) (*HTTPSink, error) {
+	if cfg.UpstreamURL == "" {
+		return nil, fmt.Errorf("upstream URL is required")
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: cfg.IgnoreSSL,
+		},
+		MaxIdleConns:    10,
+		IdleConnTimeout: 30 * time.Second,
+	}
+
+	return &HTTPSink{
+		config: cfg,
+		client: &http.Client{Transport: transport, Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Publish POSTs payload to the configured upstream URL with packet/MQTT
+// metadata set as request headers.
+func (s *HTTPSink) Publish(ctx context.Context, topic string, payload []byte, meta PacketMeta) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.UpstreamURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tzsp-qingping-exporter/1.0")
+
+	if meta.ReassembledFlow != "" {
+		req.Header.Set("X-Reassembled-Flow", meta.ReassembledFlow)
+		if meta.HTTPMethod != "" {
+			req.Header.Set("X-Original-Method", meta.HTTPMethod)
+		}
+		if meta.HTTPURL != "" {
+			req.Header.Set("X-Original-URL", meta.HTTPURL)
+		}
+	} else {
+		if meta.SrcIP != "" {
+			req.Header.Set("X-Source-IP", meta.SrcIP)
+		}
+		if meta.DstIP != "" {
+			req.Header.Set("X-Destination-IP", meta.DstIP)
+		}
+		req.Header.Set("X-Destination-Port", fmt.Sprintf("%d", meta.DstPort))
+		req.Header.Set("X-Protocol", meta.Protocol)
+		req.Header.Set("X-Timestamp", fmt.Sprintf("%d", meta.Timestamp))
+		if topic != "" {
+			req.Header.Set("X-MQTT-Topic", topic)
+		}
+		req.Header.Set("X-MQTT-QoS", fmt.Sprintf("%d", meta.MQTTQoS))
+		req.Header.Set("X-MQTT-Retain", fmt.Sprintf("%t", meta.MQTTRetain))
+		req.Header.Set("X-MQTT-Dup", fmt.Sprintf("%t", meta.MQTTDup))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if s.config.IgnoreHTTPErrors {
+			return nil
+		}
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if s.config.IgnoreHTTPErrors {
+			return nil
+		}
+		return fmt.Errorf("upstream returned non-OK status: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// Close releases idle connections held by the sink's HTTP client.
+func (s *HTTPSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,73 @@
+package qingping
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pavelkim/tzsp_server/internal/kafka"
+)
+
+// KafkaSinkConfig configures a KafkaSink.
+type KafkaSinkConfig struct {
+	BrokerAddr string
+	Topic      string
+	ClientID   string
+}
+
+// KafkaSink produces each message's payload to a fixed Kafka topic via
+// internal/kafka, a minimal fire-and-forget (acks=0) producer.
+type KafkaSink struct {
+	config KafkaSinkConfig
+
+	mu       sync.Mutex
+	producer *kafka.Producer
+}
+
+// NewKafkaSink creates a KafkaSink and connects to cfg.BrokerAddr.
+func NewKafkaSink(cfg KafkaSinkConfig) (*KafkaSink, error) {
+	if cfg.BrokerAddr == "" || cfg.Topic == "" {
+		return nil, fmt.Errorf("Kafka broker address and topic are required")
+	}
+
+	producer, err := kafka.Dial(cfg.BrokerAddr, cfg.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Kafka broker: %w", err)
+	}
+	return &KafkaSink{config: cfg, producer: producer}, nil
+}
+
+// Publish produces payload to the configured Kafka topic. topic and meta
+// are not carried onto the wire (internal/kafka's producer has no
+// headers support); they're accepted to satisfy the Sink interface.
+func (s *KafkaSink) Publish(ctx context.Context, topic string, payload []byte, meta PacketMeta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.producer == nil {
+		producer, err := kafka.Dial(s.config.BrokerAddr, s.config.ClientID)
+		if err != nil {
+			return fmt.Errorf("failed to reconnect to Kafka broker: %w", err)
+		}
+		s.producer = producer
+	}
+
+	if err := s.producer.Produce(s.config.Topic, payload); err != nil {
+		s.producer.Close()
+		s.producer = nil
+		return fmt.Errorf("failed to produce to Kafka: %w", err)
+	}
+	return nil
+}
+
+// Close closes the sink's Kafka connection, if one is open.
+func (s *KafkaSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.producer == nil {
+		return nil
+	}
+	err := s.producer.Close()
+	s.producer = nil
+	return err
+}
@@ -0,0 +1,92 @@
+package qingping
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pavelkim/tzsp_server/internal/mqtt"
+)
+
+// MQTTSinkConfig configures an MQTTSink.
+type MQTTSinkConfig struct {
+	BrokerAddr string
+	ClientID   string
+	// TopicTemplate is the topic Publish re-publishes to. The literal
+	// substring "{topic}" is replaced with the message's original MQTT
+	// topic (or "" for a reassembled HTTP message); an empty template
+	// re-publishes to the original topic unchanged.
+	TopicTemplate string
+}
+
+// MQTTSink re-publishes each message's payload to a (possibly different)
+// MQTT broker, optionally remapping its topic via TopicTemplate. It holds
+// one long-lived connection, reconnecting lazily the next time Publish is
+// called after a failure.
+type MQTTSink struct {
+	config MQTTSinkConfig
+
+	mu     sync.Mutex
+	client *mqtt.Client
+}
+
+// NewMQTTSink creates an MQTTSink and makes its first connection attempt
+// to cfg.BrokerAddr.
+func NewMQTTSink(cfg MQTTSinkConfig) (*MQTTSink, error) {
+	if cfg.BrokerAddr == "" {
+		return nil, fmt.Errorf("MQTT broker address is required")
+	}
+
+	s := &MQTTSink{config: cfg}
+	client, err := mqtt.Dial(cfg.BrokerAddr, cfg.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT re-publish broker: %w", err)
+	}
+	s.client = client
+	return s, nil
+}
+
+// Publish re-publishes payload to the topic derived from s.config and the
+// message's original topic.
+func (s *MQTTSink) Publish(ctx context.Context, topic string, payload []byte, meta PacketMeta) error {
+	outTopic := s.resolveTopic(topic)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		client, err := mqtt.Dial(s.config.BrokerAddr, s.config.ClientID)
+		if err != nil {
+			return fmt.Errorf("failed to reconnect to MQTT re-publish broker: %w", err)
+		}
+		s.client = client
+	}
+
+	if err := s.client.Publish(outTopic, payload); err != nil {
+		s.client.Close()
+		s.client = nil
+		return fmt.Errorf("failed to publish to MQTT re-publish broker: %w", err)
+	}
+	return nil
+}
+
+// resolveTopic applies s.config.TopicTemplate to originalTopic.
+func (s *MQTTSink) resolveTopic(originalTopic string) string {
+	if s.config.TopicTemplate == "" {
+		return originalTopic
+	}
+	return strings.ReplaceAll(s.config.TopicTemplate, "{topic}", originalTopic)
+}
+
+// Close closes the sink's MQTT connection, if one is open.
+func (s *MQTTSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
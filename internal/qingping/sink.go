@@ -0,0 +1,187 @@
+package qingping
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pavelkim/tzsp_server/internal/logger"
+)
+
+// PacketMeta carries the packet/MQTT (or reassembled-HTTP) metadata that
+// accompanies a Publish call, so a Sink implementation doesn't need to
+// know about decoder.PacketInfo or mqtt.Message directly.
+type PacketMeta struct {
+	Timestamp int64
+	SrcIP     string
+	SrcPort   uint16
+	DstIP     string
+	DstPort   uint16
+	Protocol  string
+
+	// MQTTTopic/MQTTQoS/MQTTRetain/MQTTDup are set when payload came
+	// from a decoded MQTT PUBLISH (see Exporter.Export).
+	MQTTTopic  string
+	MQTTQoS    uint8
+	MQTTRetain bool
+	MQTTDup    bool
+
+	// ReassembledFlow/HTTPMethod/HTTPURL are set instead of the MQTT
+	// fields above when payload came from a reassembled HTTP request
+	// body (see Exporter.ExportHTTPMessage).
+	ReassembledFlow string
+	HTTPMethod      string
+	HTTPURL         string
+}
+
+// Sink is one fan-out destination for a QingPing Exporter's decoded
+// messages. topic is the MQTT topic the message was published on, or ""
+// when payload came from a reassembled HTTP body; the MQTT re-publish
+// sink uses it to route the message, the others (Kafka included, which
+// always produces to its configured topic) ignore it.
+type Sink interface {
+	Publish(ctx context.Context, topic string, payload []byte, meta PacketMeta) error
+	Close() error
+}
+
+// BackpressurePolicy controls what a sink's bounded queue does once full.
+type BackpressurePolicy string
+
+const (
+	// BackpressureBlock makes Export wait for room in the queue, so a
+	// stalled sink back-pressures the whole packet pipeline.
+	BackpressureBlock BackpressurePolicy = "block"
+	// BackpressureDropOldest discards the oldest queued job to make room
+	// for the new one, trading delivery completeness for a pipeline that
+	// never stalls on a slow sink.
+	BackpressureDropOldest BackpressurePolicy = "drop_oldest"
+)
+
+// defaultSinkQueueSize is used when a sink's configured QueueSize is <= 0.
+const defaultSinkQueueSize = 256
+
+// sinkJob is one queued Publish call.
+type sinkJob struct {
+	topic   string
+	payload []byte
+	meta    PacketMeta
+}
+
+// sinkHandle runs one Sink's own worker goroutine, draining a bounded
+// queue so a slow or stuck sink (e.g. an unreachable HTTP endpoint) does
+// not stall the packet pipeline feeding Exporter.Export while the queue
+// has room; once it is full, policy decides whether Export waits (block)
+// or the oldest job is dropped (drop_oldest). It tracks
+// per-sink success/failure/dropped counters for GetStats.
+type sinkHandle struct {
+	name   string
+	sink   Sink
+	policy BackpressurePolicy
+	queue  chan sinkJob
+	logger *logger.Logger
+	done   chan struct{}
+
+	success uint64
+	failure uint64
+	dropped uint64
+}
+
+// newSinkHandle wraps sink in a sinkHandle and starts its worker
+// goroutine. A queueSize <= 0 uses defaultSinkQueueSize.
+func newSinkHandle(name string, sink Sink, queueSize int, policy BackpressurePolicy, log *logger.Logger) *sinkHandle {
+	if queueSize <= 0 {
+		queueSize = defaultSinkQueueSize
+	}
+	if policy == "" {
+		policy = BackpressureBlock
+	}
+
+	h := &sinkHandle{
+		name:   name,
+		sink:   sink,
+		policy: policy,
+		queue:  make(chan sinkJob, queueSize),
+		logger: log,
+		done:   make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+// run drains queue, calling sink.Publish for each job, until the queue is
+// closed (see sinkHandle.close).
+func (h *sinkHandle) run() {
+	defer close(h.done)
+	for job := range h.queue {
+		err := h.sink.Publish(context.Background(), job.topic, job.payload, job.meta)
+		if err != nil {
+			atomic.AddUint64(&h.failure, 1)
+			h.logger.Warn("QingPing sink publish failed",
+				"sink", h.name, "topic", job.topic, "error", err)
+			continue
+		}
+		atomic.AddUint64(&h.success, 1)
+	}
+}
+
+// enqueue queues job for the sink's worker, applying h.policy if the
+// queue is already full.
+func (h *sinkHandle) enqueue(job sinkJob) {
+	if h.policy == BackpressureDropOldest {
+		select {
+		case h.queue <- job:
+		default:
+			select {
+			case <-h.queue:
+				atomic.AddUint64(&h.dropped, 1)
+			default:
+			}
+			select {
+			case h.queue <- job:
+			default:
+				// Another goroutine refilled the slot first; drop this
+				// job rather than block, consistent with drop_oldest.
+				atomic.AddUint64(&h.dropped, 1)
+			}
+		}
+		return
+	}
+
+	h.queue <- job // BackpressureBlock: propagate backpressure to Export's caller
+}
+
+// stats returns a snapshot of this sink's counters and current queue
+// depth, for Exporter.GetStats.
+func (h *sinkHandle) stats() map[string]interface{} {
+	return map[string]interface{}{
+		"success":     atomic.LoadUint64(&h.success),
+		"failure":     atomic.LoadUint64(&h.failure),
+		"dropped":     atomic.LoadUint64(&h.dropped),
+		"queue_depth": len(h.queue),
+	}
+}
+
+// close drains and stops the worker goroutine, then closes the
+// underlying Sink.
+func (h *sinkHandle) close() {
+	close(h.queue)
+	<-h.done
+	if err := h.sink.Close(); err != nil {
+		h.logger.Warn("QingPing sink close failed", "sink", h.name, "error", err)
+	}
+}
+
+// closeSinks closes every handle in handles, waiting for each worker to
+// drain its queue first.
+func closeSinks(handles []*sinkHandle) {
+	var wg sync.WaitGroup
+	wg.Add(len(handles))
+	for _, h := range handles {
+		h := h
+		go func() {
+			defer wg.Done()
+			h.close()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,369 @@
+// Package reassembly reconstructs TCP byte streams from decoded TZSP
+// packets so application-layer payloads (e.g. QingPing sensor HTTP POST
+// bodies) can be recovered even when they are split across multiple
+// segments.
+package reassembly
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
+	"github.com/google/gopacket/tcpassembly/tcpreader"
+)
+
+// Config controls how the reassembly Manager buffers and expires streams,
+// and which of its built-in StreamFactory extractors are active.
+type Config struct {
+	// IdleTimeout drops a flow's buffered bytes if no new segment for it
+	// has arrived in this long.
+	IdleTimeout time.Duration
+	// MaxBufferBytes bounds how many bytes a single stream may buffer
+	// while waiting for a complete HTTP request/response or TLS
+	// handshake message.
+	MaxBufferBytes int
+	// EnableHTTP recovers HTTP request/response pairs (see HTTPMessage).
+	EnableHTTP bool
+	// EnableTLS recovers JA3/JA3S fingerprints from ClientHello/
+	// ServerHello handshake messages (see TLSFingerprint).
+	EnableTLS bool
+}
+
+// HTTPMessage is a completed HTTP request or response recovered from a
+// reassembled TCP stream.
+type HTTPMessage struct {
+	FlowKey   string
+	IsRequest bool
+	Method    string
+	URL       string
+	Headers   http.Header
+	Body      []byte
+	Timestamp time.Time
+}
+
+const defaultMaxBufferBytes = 64 * 1024
+
+// defaultIdleTimeout is used when Config.IdleTimeout is unset.
+const defaultIdleTimeout = 30 * time.Second
+
+// Manager drives a tcpassembly.Assembler and publishes completed
+// HTTPMessages and TLSFingerprints to subscribers. tcpassembly.Assembler
+// itself isn't safe for concurrent use, so mu serializes every call into
+// it; this lets Manager be shared by a worker pool that doesn't shard
+// packets by flow. Manager also owns its own idle-expiry loop, driven by
+// Config.IdleTimeout, so callers don't have to poll it on a timer of
+// their own.
+type Manager struct {
+	mu           sync.Mutex
+	assembler    *tcpassembly.Assembler
+	factory      *streamFactory
+	messages     chan *HTTPMessage
+	fingerprints chan *TLSFingerprint
+	idleTimeout  time.Duration
+	done         chan struct{}
+}
+
+// NewManager creates a Manager ready to accept TCP segments and starts its
+// idle-expiry loop.
+func NewManager(cfg Config) *Manager {
+	if cfg.MaxBufferBytes <= 0 {
+		cfg.MaxBufferBytes = defaultMaxBufferBytes
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+
+	messages := make(chan *HTTPMessage, 64)
+	fingerprints := make(chan *TLSFingerprint, 64)
+	factory := &streamFactory{
+		maxBufferBytes: cfg.MaxBufferBytes,
+		enableHTTP:     cfg.EnableHTTP,
+		enableTLS:      cfg.EnableTLS,
+		messages:       messages,
+		fingerprints:   fingerprints,
+	}
+	pool := tcpassembly.NewStreamPool(factory)
+
+	m := &Manager{
+		assembler:    tcpassembly.NewAssembler(pool),
+		factory:      factory,
+		messages:     messages,
+		fingerprints: fingerprints,
+		idleTimeout:  cfg.IdleTimeout,
+		done:         make(chan struct{}),
+	}
+	go m.idleLoop()
+	return m
+}
+
+// idleLoop periodically flushes assembler streams and evicts unmatched
+// streamFactory.pending requests that have sat longer than idleTimeout,
+// e.g. one-way traffic or a request whose response leg was filtered out.
+// Without this, pending grows without bound over a long-running capture.
+func (m *Manager) idleLoop() {
+	ticker := time.NewTicker(m.idleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-m.idleTimeout)
+			m.FlushOlderThan(cutoff)
+			m.factory.evictOlderThan(cutoff)
+		}
+	}
+}
+
+// Messages returns the channel completed HTTPMessages are posted to.
+func (m *Manager) Messages() <-chan *HTTPMessage {
+	return m.messages
+}
+
+// Fingerprints returns the channel completed TLSFingerprints are posted
+// to.
+func (m *Manager) Fingerprints() <-chan *TLSFingerprint {
+	return m.fingerprints
+}
+
+// AssemblePacket feeds a decoded IPv4/IPv6 packet containing a TCP segment
+// into the assembler. Packets without a TCP layer are ignored.
+func (m *Manager) AssemblePacket(data []byte, timestamp time.Time) error {
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+
+	tcpLayer := packet.Layer(layers.LayerTypeTCP)
+	if tcpLayer == nil {
+		return nil
+	}
+	tcp, ok := tcpLayer.(*layers.TCP)
+	if !ok {
+		return nil
+	}
+
+	var netFlow gopacket.Flow
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		netFlow = ipLayer.(*layers.IPv4).NetworkFlow()
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		netFlow = ipLayer.(*layers.IPv6).NetworkFlow()
+	} else {
+		return fmt.Errorf("no IPv4/IPv6 layer to key TCP flow on")
+	}
+
+	m.mu.Lock()
+	m.assembler.AssembleWithTimestamp(netFlow, tcp, timestamp)
+	m.mu.Unlock()
+	return nil
+}
+
+// FlushOlderThan closes out streams that have been idle since before t,
+// discarding any partially buffered request/response.
+func (m *Manager) FlushOlderThan(t time.Time) (flushed, closed int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.assembler.FlushOlderThan(t)
+}
+
+// Close stops the idle-expiry loop, flushes all remaining streams, and
+// closes the message/fingerprint channels.
+func (m *Manager) Close() error {
+	close(m.done)
+	m.mu.Lock()
+	m.assembler.FlushAll()
+	m.mu.Unlock()
+	close(m.messages)
+	close(m.fingerprints)
+	return nil
+}
+
+// streamFactory builds one stream per TCP half-connection, dispatching it
+// to the HTTP or TLS extractor based on its first byte, and matches HTTP
+// requests to responses by reversing the flow tuple.
+type streamFactory struct {
+	maxBufferBytes int
+	enableHTTP     bool
+	enableTLS      bool
+	messages       chan *HTTPMessage
+	fingerprints   chan *TLSFingerprint
+
+	mu      sync.Mutex
+	pending map[string]*HTTPMessage // keyed by the request's flow key
+}
+
+// evictOlderThan drops pending requests whose response never arrived
+// before cutoff, e.g. one-way traffic, a filtered-out reverse leg, or a
+// crashed peer.
+func (f *streamFactory) evictOlderThan(cutoff time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key, msg := range f.pending {
+		if msg.Timestamp.Before(cutoff) {
+			delete(f.pending, key)
+		}
+	}
+}
+
+func flowKey(net, transport gopacket.Flow) string {
+	return net.String() + ":" + transport.String()
+}
+
+// New implements tcpassembly.StreamFactory.
+func (f *streamFactory) New(netFlow, transport gopacket.Flow) tcpassembly.Stream {
+	reader := tcpreader.NewReaderStream()
+	key := flowKey(netFlow, transport)
+	reverseKey := flowKey(netFlow.Reverse(), transport.Reverse())
+
+	go f.run(&reader, key, reverseKey)
+
+	return &reader
+}
+
+func (f *streamFactory) run(reader *tcpreader.ReaderStream, key, reverseKey string) {
+	// Always drain the stream so the assembler can reclaim its pages,
+	// even if we never find a well-formed HTTP message on it.
+	defer tcpreader.DiscardBytesToEOF(reader)
+
+	buffered := &io.LimitedReader{R: reader, N: int64(f.maxBufferBytes)}
+	br := bufio.NewReader(buffered)
+
+	// Peek at the first byte to decide what this half-connection looks
+	// like: a TLS record, an HTTP response, or (the fallback) an HTTP
+	// request.
+	firstByte, err := br.Peek(1)
+	if err != nil {
+		return
+	}
+
+	if firstByte[0] == tlsContentTypeHandshake {
+		if f.enableTLS {
+			f.runTLS(br, key, reverseKey)
+		}
+		return
+	}
+	if !f.enableHTTP {
+		return
+	}
+
+	now := time.Now()
+
+	if firstByte[0] == 'H' { // likely "HTTP/1.x ..." status line
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		msg := &HTTPMessage{
+			FlowKey:   reverseKey,
+			IsRequest: false,
+			Headers:   resp.Header,
+			Body:      body,
+			Timestamp: now,
+		}
+		f.publish(reverseKey, msg)
+		return
+	}
+
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	req.Body.Close()
+
+	msg := &HTTPMessage{
+		FlowKey:   key,
+		IsRequest: true,
+		Method:    req.Method,
+		URL:       req.URL.String(),
+		Headers:   req.Header,
+		Body:      body,
+		Timestamp: now,
+	}
+	f.publish(key, msg)
+}
+
+// runTLS reads the first handshake message off br and fingerprints it: a
+// ClientHello (JA3) on the side that spoke first, a ServerHello (JA3S) on
+// the reverse side. key identifies this half-connection's own flow;
+// ServerHello fingerprints are published under reverseKey so both halves
+// of one TLS connection share a flow key in the output.
+func (f *streamFactory) runTLS(br *bufio.Reader, key, reverseKey string) {
+	msgType, body, err := readTLSHandshakeMessage(br, f.maxBufferBytes)
+	if err != nil {
+		return
+	}
+
+	switch msgType {
+	case tlsHandshakeClientHello:
+		fp, err := ParseClientHello(body)
+		if err != nil {
+			return
+		}
+		fp.FlowKey = key
+		f.emitFingerprint(fp)
+	case tlsHandshakeServerHello:
+		fp, err := ParseServerHello(body)
+		if err != nil {
+			return
+		}
+		fp.FlowKey = reverseKey
+		f.emitFingerprint(fp)
+	}
+}
+
+func (f *streamFactory) emitFingerprint(fp *TLSFingerprint) {
+	select {
+	case f.fingerprints <- fp:
+	default:
+		// Subscriber isn't keeping up; drop rather than block the
+		// assembler goroutine.
+	}
+}
+
+// publish emits msg. A request is emitted as soon as it is read, so
+// callers get it whether or not a response follows, and is also kept
+// pending under key; a response is emitted tagged with the pending
+// request's method and URL when its counterpart on the reverse flow is
+// found, and as-is otherwise.
+func (f *streamFactory) publish(key string, msg *HTTPMessage) {
+	f.mu.Lock()
+	if f.pending == nil {
+		f.pending = make(map[string]*HTTPMessage)
+	}
+
+	if msg.IsRequest {
+		f.pending[key] = msg
+		f.mu.Unlock()
+		f.emit(msg)
+		return
+	}
+
+	// msg is a response; key is the originating request's flow key.
+	if req, ok := f.pending[key]; ok {
+		delete(f.pending, key)
+		f.mu.Unlock()
+		msg.Method = req.Method
+		msg.URL = req.URL
+		f.emit(msg)
+		return
+	}
+	f.mu.Unlock()
+	f.emit(msg)
+}
+
+func (f *streamFactory) emit(msg *HTTPMessage) {
+	select {
+	case f.messages <- msg:
+	default:
+		// Subscriber isn't keeping up; drop rather than block the
+		// assembler goroutine.
+	}
+}
@@ -0,0 +1,310 @@
+package reassembly
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TLSFingerprint is a JA3 (ClientHello) or JA3S (ServerHello) fingerprint
+// recovered from a reassembled TCP stream.
+type TLSFingerprint struct {
+	FlowKey string
+	// IsClientHello distinguishes a JA3 (true) from a JA3S (false)
+	// fingerprint; the two use different field sets (see JA3/JA3S).
+	IsClientHello bool
+	// SNI is the ClientHello's server_name extension value, if present.
+	// Always empty for a ServerHello.
+	SNI string
+	// JA3/JA3S is the raw comma-joined field string the hash is computed
+	// over, kept alongside JA3Hash for operators who want to diff it by
+	// hand against known fingerprints.
+	JA3     string
+	JA3Hash string
+}
+
+// tlsRecordHeader is the 5-byte record layer header.
+const tlsRecordHeaderLen = 5
+
+const (
+	tlsContentTypeHandshake = 0x16
+
+	tlsHandshakeClientHello = 0x01
+	tlsHandshakeServerHello = 0x02
+)
+
+// readTLSHandshakeMessage reads one or more TLS records off br, all of
+// content-type handshake, accumulating their payloads until a complete
+// handshake message (type + 3-byte length + body) has been read. This
+// handles a ClientHello that's split across multiple records, which is
+// common for large ones (many extensions, a big session ticket, ...).
+func readTLSHandshakeMessage(br *bufio.Reader, maxBytes int) (msgType byte, body []byte, err error) {
+	var acc []byte
+
+	for {
+		header := make([]byte, tlsRecordHeaderLen)
+		if _, err := readFull(br, header); err != nil {
+			return 0, nil, err
+		}
+		if header[0] != tlsContentTypeHandshake {
+			return 0, nil, fmt.Errorf("not a TLS handshake record (content type 0x%02x)", header[0])
+		}
+		recLen := int(header[3])<<8 | int(header[4])
+		if recLen <= 0 || recLen > maxBytes {
+			return 0, nil, fmt.Errorf("invalid TLS record length %d", recLen)
+		}
+
+		rec := make([]byte, recLen)
+		if _, err := readFull(br, rec); err != nil {
+			return 0, nil, err
+		}
+		acc = append(acc, rec...)
+		if len(acc) > maxBytes {
+			return 0, nil, fmt.Errorf("TLS handshake message exceeds %d bytes", maxBytes)
+		}
+
+		if len(acc) < 4 {
+			continue
+		}
+		msgLen := int(acc[1])<<16 | int(acc[2])<<8 | int(acc[3])
+		if len(acc) >= 4+msgLen {
+			return acc[0], acc[4 : 4+msgLen], nil
+		}
+	}
+}
+
+func readFull(br *bufio.Reader, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := br.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// tlsExtension is one parsed ClientHello/ServerHello extension.
+type tlsExtension struct {
+	typ  uint16
+	data []byte
+}
+
+// parseExtensions reads a 2-byte-prefixed extensions list, as found at
+// the tail of both ClientHello and (TLS 1.2+) ServerHello.
+func parseExtensions(data []byte) ([]tlsExtension, error) {
+	if len(data) < 2 {
+		return nil, nil
+	}
+	total := int(data[0])<<8 | int(data[1])
+	data = data[2:]
+	if total > len(data) {
+		return nil, fmt.Errorf("extensions length %d exceeds remaining %d bytes", total, len(data))
+	}
+	data = data[:total]
+
+	var exts []tlsExtension
+	for len(data) >= 4 {
+		typ := uint16(data[0])<<8 | uint16(data[1])
+		length := int(data[2])<<8 | int(data[3])
+		data = data[4:]
+		if length > len(data) {
+			return nil, fmt.Errorf("extension 0x%04x length %d exceeds remaining %d bytes", typ, length, len(data))
+		}
+		exts = append(exts, tlsExtension{typ: typ, data: data[:length]})
+		data = data[length:]
+	}
+	return exts, nil
+}
+
+// isGREASE reports whether v is one of the reserved GRESE values
+// (RFC 8701) TLS clients/servers use as intentionally-ignorable
+// placeholders; JA3/JA3S exclude these from the fingerprint so that
+// GREASE randomization doesn't change the hash.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a
+}
+
+// parseClientHelloSNI extracts the server_name extension's hostname, if
+// present, from a ClientHello's extensions.
+func parseClientHelloSNI(data []byte) string {
+	// server_name_list: 2-byte length, then entries of
+	// (1-byte type, 2-byte length, name).
+	if len(data) < 2 {
+		return ""
+	}
+	data = data[2:]
+	for len(data) >= 3 {
+		typ := data[0]
+		length := int(data[1])<<8 | int(data[2])
+		data = data[3:]
+		if length > len(data) {
+			return ""
+		}
+		if typ == 0 { // host_name
+			return string(data[:length])
+		}
+		data = data[length:]
+	}
+	return ""
+}
+
+// ParseClientHello parses a ClientHello handshake body (as returned by
+// readTLSHandshakeMessage) into a JA3 fingerprint.
+func ParseClientHello(body []byte) (*TLSFingerprint, error) {
+	if len(body) < 2+32+1 {
+		return nil, fmt.Errorf("ClientHello too short")
+	}
+	version := uint16(body[0])<<8 | uint16(body[1])
+	pos := 2 + 32
+
+	sessionIDLen := int(body[pos])
+	pos++
+	if pos+sessionIDLen > len(body) {
+		return nil, fmt.Errorf("ClientHello session_id overruns message")
+	}
+	pos += sessionIDLen
+
+	if pos+2 > len(body) {
+		return nil, fmt.Errorf("ClientHello truncated before cipher_suites")
+	}
+	cipherLen := int(body[pos])<<8 | int(body[pos+1])
+	pos += 2
+	if pos+cipherLen > len(body) {
+		return nil, fmt.Errorf("ClientHello cipher_suites overruns message")
+	}
+	var ciphers []string
+	for i := 0; i < cipherLen; i += 2 {
+		c := uint16(body[pos+i])<<8 | uint16(body[pos+i+1])
+		if !isGREASE(c) {
+			ciphers = append(ciphers, strconv.Itoa(int(c)))
+		}
+	}
+	pos += cipherLen
+
+	if pos+1 > len(body) {
+		return nil, fmt.Errorf("ClientHello truncated before compression_methods")
+	}
+	compLen := int(body[pos])
+	pos += 1 + compLen
+	if pos > len(body) {
+		return nil, fmt.Errorf("ClientHello compression_methods overruns message")
+	}
+
+	var extTypes, curves, pointFormats []string
+	var sni string
+	if pos < len(body) {
+		exts, err := parseExtensions(body[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("ClientHello extensions: %w", err)
+		}
+		for _, ext := range exts {
+			if !isGREASE(ext.typ) {
+				extTypes = append(extTypes, strconv.Itoa(int(ext.typ)))
+			}
+			switch ext.typ {
+			case 0: // server_name
+				sni = parseClientHelloSNI(ext.data)
+			case 10: // supported_groups (elliptic curves)
+				if len(ext.data) >= 2 {
+					listLen := int(ext.data[0])<<8 | int(ext.data[1])
+					list := ext.data[2:]
+					if listLen > len(list) {
+						listLen = len(list)
+					}
+					for i := 0; i+1 < listLen; i += 2 {
+						c := uint16(list[i])<<8 | uint16(list[i+1])
+						if !isGREASE(c) {
+							curves = append(curves, strconv.Itoa(int(c)))
+						}
+					}
+				}
+			case 11: // ec_point_formats
+				if len(ext.data) >= 1 {
+					listLen := int(ext.data[0])
+					list := ext.data[1:]
+					if listLen > len(list) {
+						listLen = len(list)
+					}
+					for i := 0; i < listLen; i++ {
+						pointFormats = append(pointFormats, strconv.Itoa(int(list[i])))
+					}
+				}
+			}
+		}
+	}
+
+	ja3 := fmt.Sprintf("%d,%s,%s,%s,%s",
+		version,
+		strings.Join(ciphers, "-"),
+		strings.Join(extTypes, "-"),
+		strings.Join(curves, "-"),
+		strings.Join(pointFormats, "-"))
+
+	return &TLSFingerprint{
+		IsClientHello: true,
+		SNI:           sni,
+		JA3:           ja3,
+		JA3Hash:       ja3Hash(ja3),
+	}, nil
+}
+
+// ParseServerHello parses a ServerHello handshake body into a JA3S
+// fingerprint: the same recipe as JA3, but over (version, cipher,
+// extension types) only — a ServerHello picks one cipher and has no
+// curve/point-format lists of its own.
+func ParseServerHello(body []byte) (*TLSFingerprint, error) {
+	if len(body) < 2+32+1 {
+		return nil, fmt.Errorf("ServerHello too short")
+	}
+	version := uint16(body[0])<<8 | uint16(body[1])
+	pos := 2 + 32
+
+	sessionIDLen := int(body[pos])
+	pos++
+	if pos+sessionIDLen > len(body) {
+		return nil, fmt.Errorf("ServerHello session_id overruns message")
+	}
+	pos += sessionIDLen
+
+	if pos+2 > len(body) {
+		return nil, fmt.Errorf("ServerHello truncated before cipher_suite")
+	}
+	cipher := uint16(body[pos])<<8 | uint16(body[pos+1])
+	pos += 2
+
+	pos++ // compression_method (1 byte, always null/0 post-TLS1.3 but present)
+	if pos > len(body) {
+		return nil, fmt.Errorf("ServerHello truncated before extensions")
+	}
+
+	var extTypes []string
+	if pos < len(body) {
+		exts, err := parseExtensions(body[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("ServerHello extensions: %w", err)
+		}
+		for _, ext := range exts {
+			if !isGREASE(ext.typ) {
+				extTypes = append(extTypes, strconv.Itoa(int(ext.typ)))
+			}
+		}
+	}
+
+	ja3s := fmt.Sprintf("%d,%d,%s", version, cipher, strings.Join(extTypes, "-"))
+
+	return &TLSFingerprint{
+		IsClientHello: false,
+		JA3:           ja3s,
+		JA3Hash:       ja3Hash(ja3s),
+	}, nil
+}
+
+func ja3Hash(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
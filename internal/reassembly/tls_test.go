@@ -0,0 +1,182 @@
+package reassembly
+
+import (
+	"testing"
+)
+
+// clientHelloExt is a (type, data) pair used to build synthetic
+// ClientHello/ServerHello extension blocks for tests.
+type clientHelloExt struct {
+	typ  uint16
+	data []byte
+}
+
+func encodeExtensions(exts []clientHelloExt) []byte {
+	var body []byte
+	for _, e := range exts {
+		body = append(body, byte(e.typ>>8), byte(e.typ))
+		body = append(body, byte(len(e.data)>>8), byte(len(e.data)))
+		body = append(body, e.data...)
+	}
+	out := []byte{byte(len(body) >> 8), byte(len(body))}
+	return append(out, body...)
+}
+
+func sniExtension(host string) clientHelloExt {
+	name := []byte(host)
+	entry := append([]byte{0, byte(len(name) >> 8), byte(len(name))}, name...) // host_name entry
+	list := append([]byte{byte(len(entry) >> 8), byte(len(entry))}, entry...)
+	return clientHelloExt{typ: 0, data: list}
+}
+
+func supportedGroupsExtension(groups []uint16) clientHelloExt {
+	var list []byte
+	for _, g := range groups {
+		list = append(list, byte(g>>8), byte(g))
+	}
+	data := append([]byte{byte(len(list) >> 8), byte(len(list))}, list...)
+	return clientHelloExt{typ: 10, data: data}
+}
+
+func ecPointFormatsExtension(formats []byte) clientHelloExt {
+	data := append([]byte{byte(len(formats))}, formats...)
+	return clientHelloExt{typ: 11, data: data}
+}
+
+func buildClientHello(version uint16, ciphers []uint16, exts []clientHelloExt) []byte {
+	var body []byte
+	body = append(body, byte(version>>8), byte(version))
+	body = append(body, make([]byte, 32)...) // random
+	body = append(body, 0)                   // session_id_len = 0
+
+	var cipherBytes []byte
+	for _, c := range ciphers {
+		cipherBytes = append(cipherBytes, byte(c>>8), byte(c))
+	}
+	body = append(body, byte(len(cipherBytes)>>8), byte(len(cipherBytes)))
+	body = append(body, cipherBytes...)
+
+	body = append(body, 1, 0) // compression_methods: len 1, method 0 (null)
+
+	body = append(body, encodeExtensions(exts)...)
+	return body
+}
+
+func buildServerHello(version, cipher uint16, exts []clientHelloExt) []byte {
+	var body []byte
+	body = append(body, byte(version>>8), byte(version))
+	body = append(body, make([]byte, 32)...) // random
+	body = append(body, 0)                   // session_id_len = 0
+	body = append(body, byte(cipher>>8), byte(cipher))
+	body = append(body, 0) // compression_method
+
+	body = append(body, encodeExtensions(exts)...)
+	return body
+}
+
+func TestParseClientHello(t *testing.T) {
+	body := buildClientHello(
+		0x0303, // TLS 1.2
+		[]uint16{0x0a0a, 0x1301, 0xc02f}, // GREASE + TLS_AES_128_GCM_SHA256 + ECDHE-RSA-AES128-GCM-SHA256
+		[]clientHelloExt{
+			sniExtension("example.com"),
+			supportedGroupsExtension([]uint16{0x6a6a, 0x001d, 0x0017}), // GREASE + x25519 + secp256r1
+			ecPointFormatsExtension([]byte{0}),
+		},
+	)
+
+	fp, err := ParseClientHello(body)
+	if err != nil {
+		t.Fatalf("ParseClientHello returned error: %v", err)
+	}
+	if !fp.IsClientHello {
+		t.Fatal("expected IsClientHello to be true")
+	}
+	if fp.SNI != "example.com" {
+		t.Fatalf("expected SNI %q, got %q", "example.com", fp.SNI)
+	}
+	const wantJA3 = "771,4865-49199,0-10-11,29-23,0"
+	if fp.JA3 != wantJA3 {
+		t.Fatalf("expected JA3 %q, got %q", wantJA3, fp.JA3)
+	}
+	if fp.JA3Hash != ja3Hash(wantJA3) {
+		t.Fatalf("JA3Hash doesn't match ja3Hash(JA3)")
+	}
+}
+
+func TestParseClientHelloNoExtensions(t *testing.T) {
+	body := buildClientHello(0x0303, []uint16{0xc02f}, nil)
+
+	fp, err := ParseClientHello(body)
+	if err != nil {
+		t.Fatalf("ParseClientHello returned error: %v", err)
+	}
+	if fp.SNI != "" {
+		t.Fatalf("expected no SNI, got %q", fp.SNI)
+	}
+	const wantJA3 = "771,49199,,,"
+	if fp.JA3 != wantJA3 {
+		t.Fatalf("expected JA3 %q, got %q", wantJA3, fp.JA3)
+	}
+}
+
+func TestParseClientHelloTruncated(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"empty", nil},
+		{"shorter than version+random+session_id_len", make([]byte, 10)},
+		{"cipher_suites length overruns body", append(buildClientHello(0x0303, nil, nil)[:2+32+1], 0xFF, 0xFF)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseClientHello(tt.body); err == nil {
+				t.Fatal("expected an error for a truncated/malformed ClientHello")
+			}
+		})
+	}
+}
+
+func TestParseServerHello(t *testing.T) {
+	body := buildServerHello(0x0303, 0xc02f, []clientHelloExt{
+		{typ: 0x0a0a, data: nil}, // GREASE extension, excluded from JA3S
+		{typ: 0x002b, data: []byte{0x03, 0x04}},
+	})
+
+	fp, err := ParseServerHello(body)
+	if err != nil {
+		t.Fatalf("ParseServerHello returned error: %v", err)
+	}
+	if fp.IsClientHello {
+		t.Fatal("expected IsClientHello to be false")
+	}
+	if fp.SNI != "" {
+		t.Fatalf("expected ServerHello to never set SNI, got %q", fp.SNI)
+	}
+	const wantJA3S = "771,49199,43"
+	if fp.JA3 != wantJA3S {
+		t.Fatalf("expected JA3S %q, got %q", wantJA3S, fp.JA3)
+	}
+	if fp.JA3Hash != ja3Hash(wantJA3S) {
+		t.Fatalf("JA3Hash doesn't match ja3Hash(JA3)")
+	}
+}
+
+func TestParseServerHelloTruncated(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"empty", nil},
+		{"shorter than version+random+session_id_len", make([]byte, 10)},
+		{"truncated before cipher_suite", append(make([]byte, 2+32), 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseServerHello(tt.body); err == nil {
+				t.Fatal("expected an error for a truncated/malformed ServerHello")
+			}
+		})
+	}
+}
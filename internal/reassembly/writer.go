@@ -0,0 +1,175 @@
+package reassembly
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Writer is the output side of the reassembly subsystem: something that
+// wants to know about every HTTPMessage and TLSFingerprint a Manager
+// recovers, e.g. to log it or forward it to a NetFlow-style exporter.
+type Writer interface {
+	WriteHTTPMessage(msg *HTTPMessage) error
+	WriteTLSFingerprint(fp *TLSFingerprint) error
+	Close() error
+}
+
+// FileWriterConfig configures a FileWriter.
+type FileWriterConfig struct {
+	OutputFile string
+	// MaxSizeMB rotates the file once it exceeds this size; 0 disables
+	// size-based rotation.
+	MaxSizeMB int
+	// MaxBackups bounds how many rotated files are kept (OutputFile.1,
+	// .2, ...); 0 disables rotation (the file grows unbounded).
+	MaxBackups int
+}
+
+// fileWriterRecord is one JSON-lines record written by FileWriter, the
+// same shape for both an HTTPMessage and a TLSFingerprint so a single
+// file can interleave both kinds (distinguished by Kind).
+type fileWriterRecord struct {
+	Kind string `json:"kind"` // "http" or "tls"
+
+	FlowKey string `json:"flow_key"`
+
+	// http fields
+	Method string `json:"http_method,omitempty"`
+	URL    string `json:"http_url,omitempty"`
+
+	// tls fields
+	IsClientHello bool   `json:"is_client_hello,omitempty"`
+	SNI           string `json:"sni,omitempty"`
+	JA3           string `json:"ja3,omitempty"`
+	JA3Hash       string `json:"ja3_hash,omitempty"`
+}
+
+// FileWriter appends one JSON object per line to a rolling file, the same
+// size/backup-count rotation scheme internal/pcap.Writer and
+// internal/qingping.FileSink use for their own output files.
+type FileWriter struct {
+	config FileWriterConfig
+
+	mu           sync.Mutex
+	file         *os.File
+	bytesWritten int64
+}
+
+// NewFileWriter creates a FileWriter appending to cfg.OutputFile, creating
+// it if necessary.
+func NewFileWriter(cfg FileWriterConfig) (*FileWriter, error) {
+	if cfg.OutputFile == "" {
+		return nil, fmt.Errorf("output file is required")
+	}
+
+	f, size, err := openAppend(cfg.OutputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open reassembly file writer: %w", err)
+	}
+	return &FileWriter{config: cfg, file: f, bytesWritten: size}, nil
+}
+
+func openAppend(path string) (*os.File, int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+// WriteHTTPMessage implements Writer.
+func (w *FileWriter) WriteHTTPMessage(msg *HTTPMessage) error {
+	return w.writeLine(fileWriterRecord{
+		Kind:    "http",
+		FlowKey: msg.FlowKey,
+		Method:  msg.Method,
+		URL:     msg.URL,
+	})
+}
+
+// WriteTLSFingerprint implements Writer.
+func (w *FileWriter) WriteTLSFingerprint(fp *TLSFingerprint) error {
+	return w.writeLine(fileWriterRecord{
+		Kind:          "tls",
+		FlowKey:       fp.FlowKey,
+		IsClientHello: fp.IsClientHello,
+		SNI:           fp.SNI,
+		JA3:           fp.JA3,
+		JA3Hash:       fp.JA3Hash,
+	})
+}
+
+func (w *FileWriter) writeLine(record fileWriterRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reassembly record: %w", err)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.config.MaxSizeMB > 0 && w.bytesWritten > int64(w.config.MaxSizeMB)*1024*1024 {
+		if err := w.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate reassembly file writer: %w", err)
+		}
+	}
+
+	n, err := w.file.Write(line)
+	w.bytesWritten += int64(n)
+	return err
+}
+
+// rotate closes the current file, shifts backups, and opens a fresh file
+// at w.config.OutputFile. Caller must hold w.mu.
+func (w *FileWriter) rotate() error {
+	w.file.Close()
+
+	if w.config.MaxBackups > 0 {
+		for i := w.config.MaxBackups - 1; i >= 0; i-- {
+			oldName := w.backupName(i)
+			newName := w.backupName(i + 1)
+			if _, err := os.Stat(oldName); err == nil {
+				if i == w.config.MaxBackups-1 {
+					os.Remove(oldName)
+				} else {
+					os.Rename(oldName, newName)
+				}
+			}
+		}
+		if _, err := os.Stat(w.config.OutputFile); err == nil {
+			os.Rename(w.config.OutputFile, w.backupName(0))
+		}
+	}
+
+	f, _, err := openAppend(w.config.OutputFile)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.bytesWritten = 0
+	return nil
+}
+
+// backupName returns the rotated filename at index (0 is the most recent
+// backup), matching internal/pcap.Writer's naming scheme.
+func (w *FileWriter) backupName(index int) string {
+	if index == 0 {
+		return w.config.OutputFile + ".1"
+	}
+	return fmt.Sprintf("%s.%d", w.config.OutputFile, index+1)
+}
+
+// Close flushes and closes the writer's file.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy controls what happens when the pipeline queue is full
+// and a new packet arrives from the UDP read loop. It mirrors
+// qingping.BackpressurePolicy, but lives in this package since it governs
+// ingress rather than a fan-out sink.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock makes the UDP read loop wait for a free queue slot,
+	// so a saturated worker pool back-pressures the socket buffer rather
+	// than dropping packets.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropOldest discards the oldest queued packet to make room
+	// for the new one, trading completeness for a pipeline that never
+	// stalls reading the UDP socket.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+)
+
+const (
+	defaultWorkers    = 4
+	defaultQueueDepth = 1024
+)
+
+// packetJob is one buffer dequeued from the UDP socket, awaiting a
+// pipeline worker.
+type packetJob struct {
+	buf        []byte // always len == bufferSize; n is the valid prefix
+	n          int
+	remoteAddr string
+	recvTime   time.Time
+}
+
+// pipeline owns the bounded queue and worker pool that decode encapsulated
+// packets off the UDP read loop, plus the buffer pool backing job.buf so
+// workers don't allocate a fresh []byte per packet.
+type pipeline struct {
+	server   *Server
+	queue    chan *packetJob
+	policy   OverflowPolicy
+	bufPool  sync.Pool
+	workerWG sync.WaitGroup
+}
+
+// newPipeline builds a pipeline for s. workers/queueDepth/policy fall back
+// to defaultWorkers/defaultQueueDepth/OverflowBlock when unset.
+func newPipeline(s *Server, workers, queueDepth int, policy OverflowPolicy) *pipeline {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if queueDepth <= 0 {
+		queueDepth = defaultQueueDepth
+	}
+	if policy == "" {
+		policy = OverflowBlock
+	}
+
+	p := &pipeline{
+		server: s,
+		queue:  make(chan *packetJob, queueDepth),
+		policy: policy,
+	}
+	p.bufPool.New = func() interface{} { return make([]byte, s.bufferSize) }
+	return p
+}
+
+// getBuffer returns a []byte of len s.bufferSize from the pool.
+func (p *pipeline) getBuffer() []byte {
+	return p.bufPool.Get().([]byte)
+}
+
+// putBuffer returns buf to the pool for reuse by a future getBuffer call.
+func (p *pipeline) putBuffer(buf []byte) {
+	p.bufPool.Put(buf)
+}
+
+// start launches the configured number of worker goroutines, each draining
+// queue until it's closed by stop.
+func (p *pipeline) start(n int) {
+	for i := 0; i < n; i++ {
+		p.workerWG.Add(1)
+		go p.runWorker()
+	}
+}
+
+// runWorker processes jobs off the queue until it is closed, recording
+// queue depth and per-job busy time.
+func (p *pipeline) runWorker() {
+	defer p.workerWG.Done()
+	for job := range p.queue {
+		p.server.metrics.QueueDepth.Set(float64(len(p.queue)))
+
+		start := time.Now()
+		if err := p.server.processPacket(job.buf[:job.n], job.remoteAddr); err != nil {
+			p.server.logger.Debug("Failed to process packet", "error", err, "source", job.remoteAddr)
+		}
+		p.server.metrics.WorkerBusyTime.Observe(time.Since(start).Seconds())
+
+		p.putBuffer(job.buf)
+	}
+}
+
+// enqueue queues job for a worker, applying p.policy if the queue is
+// already full.
+func (p *pipeline) enqueue(ctx context.Context, job *packetJob) {
+	if p.policy == OverflowDropOldest {
+		select {
+		case p.queue <- job:
+		default:
+			select {
+			case old := <-p.queue:
+				p.putBuffer(old.buf)
+				p.server.metrics.QueueDrops.Inc()
+			default:
+			}
+			select {
+			case p.queue <- job:
+			default:
+				// Another goroutine refilled the slot first; drop this
+				// job rather than block, consistent with drop_oldest.
+				p.putBuffer(job.buf)
+				p.server.metrics.QueueDrops.Inc()
+			}
+		}
+		return
+	}
+
+	// OverflowBlock: propagate backpressure to the UDP read loop, but
+	// still honor shutdown so Stop doesn't hang forever on a full queue.
+	select {
+	case p.queue <- job:
+	case <-ctx.Done():
+		p.putBuffer(job.buf)
+	}
+}
+
+// stop closes the queue and waits for every worker to drain it.
+func (p *pipeline) stop() {
+	close(p.queue)
+	p.workerWG.Wait()
+}
@@ -4,14 +4,25 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/pavelkim/tzsp_server/internal/anomaly"
 	"github.com/pavelkim/tzsp_server/internal/decoder"
+	"github.com/pavelkim/tzsp_server/internal/filter"
 	"github.com/pavelkim/tzsp_server/internal/logger"
+	"github.com/pavelkim/tzsp_server/internal/metrics"
 	"github.com/pavelkim/tzsp_server/internal/netflow"
 	"github.com/pavelkim/tzsp_server/internal/output"
+	"github.com/pavelkim/tzsp_server/internal/parsers"
 	"github.com/pavelkim/tzsp_server/internal/pcap"
 	"github.com/pavelkim/tzsp_server/internal/qingping"
+	"github.com/pavelkim/tzsp_server/internal/reassembly"
+	"github.com/pavelkim/tzsp_server/internal/session"
 	"github.com/pavelkim/tzsp_server/internal/tzsp"
 )
 
@@ -19,44 +30,392 @@ import (
 type Server struct {
 	listenAddr    string
 	bufferSize    int
+	metricsAddr   string
 	conn          *net.UDPConn
 	tzspDecoder   *tzsp.Decoder
 	packetDecoder *decoder.Decoder
-	fileWriter    *output.FileWriter
-	pcapWriter    *pcap.Writer
-	netflowExp    *netflow.Exporter
-	qingpingExp   *qingping.Exporter
 	logger        *logger.Logger
 
+	// outputsMu guards gen below so it can be hot-swapped by
+	// ReplaceOutputs/SetNetflowExporter/SetQingpingExporter (config reload
+	// or a control command) without racing processPacket.
+	outputsMu sync.RWMutex
+	gen       *outputGeneration
+
+	// globalFilter, denySourceIPs and sampleRate gate whether a packet is
+	// decoded/exported at all, evaluated once up front in processPacket
+	// rather than per-sink like outputGeneration's fileFilter et al.
+	globalFilter  *filter.Filter
+	denySourceIPs map[string]struct{}
+	sampleRate    int
+	sampleCounter uint64
+
+	// packetsReceived/packetsDecoded/packetsWritten are updated with
+	// atomic.AddUint64 (packetsDecoded/packetsWritten from every pipeline
+	// worker, packetsReceived from the UDP read loop) and read the same
+	// way; plain ++ would race now that processPacket runs concurrently.
 	packetsReceived uint64
 	packetsDecoded  uint64
 	packetsWritten  uint64
+
+	// statsMu guards the per-EtherType/per-L4-protocol breakdown below.
+	statsMu          sync.Mutex
+	etherTypeCounts  map[uint16]uint64
+	l4ProtocolCounts map[string]uint64
+
+	// metrics is never nil: Prometheus collectors are cheap to update even
+	// when MetricsAddr is unset, so processPacket doesn't need to branch
+	// on whether scraping is enabled, only metricsServer does.
+	metrics       *metrics.Metrics
+	metricsServer *metrics.Server
+
+	// workers, queueDepth and overflowPolicy configure the pipeline
+	// (see pipeline.go) that decodes/exports packets off the UDP read
+	// loop. pipe itself is only valid between Start and Stop.
+	workers        int
+	queueDepth     int
+	overflowPolicy OverflowPolicy
+	pipe           *pipeline
+
+	// reassemblyOutputMgr and reassemblyWriter back the standalone
+	// HTTP/TLS fingerprinting output (see Config.ReassemblyMgr): unlike
+	// outputGeneration.reassemblyMgr, this one sees every TCP flow, not just ones
+	// feeding QingPing. Nil when Output.Reassembly is disabled.
+	reassemblyOutputMgr *reassembly.Manager
+	reassemblyWriter    reassembly.Writer
+}
+
+// outputGeneration bundles one hot-swappable snapshot of the output sinks
+// and their bpf_filter expressions, plus a refcount (refs) of the
+// processPacket calls currently using it. ReplaceOutputs/SetNetflowExporter/
+// SetQingpingExporter install a new *outputGeneration and then wait for the
+// old one's refs to drain to zero before closing whichever sinks it held
+// that aren't also in the new generation, so a worker still mid-export
+// against an old sink is never closed out from under it.
+type outputGeneration struct {
+	fileWriter     *output.FileWriter
+	pcapWriter     *pcap.Writer
+	netflowExp     *netflow.Exporter
+	qingpingExp    *qingping.Exporter
+	anomalyEngine  *anomaly.Engine
+	parsers        *parsers.Registry
+	sessionTracker *session.Tracker
+	reassemblyMgr  *reassembly.Manager
+	fileFilter     *filter.Filter
+	pcapFilter     *filter.Filter
+	netflowFilter  *filter.Filter
+	qingpingFilter *filter.Filter
+
+	refs sync.WaitGroup
+}
+
+// clone copies g's sink/filter pointers into a new generation with its own,
+// fresh refcount, for callers (e.g. SetNetflowExporter) that only replace
+// one sink and want every other field carried over unchanged.
+func (g *outputGeneration) clone() *outputGeneration {
+	return &outputGeneration{
+		fileWriter:     g.fileWriter,
+		pcapWriter:     g.pcapWriter,
+		netflowExp:     g.netflowExp,
+		qingpingExp:    g.qingpingExp,
+		anomalyEngine:  g.anomalyEngine,
+		parsers:        g.parsers,
+		sessionTracker: g.sessionTracker,
+		reassemblyMgr:  g.reassemblyMgr,
+		fileFilter:     g.fileFilter,
+		pcapFilter:     g.pcapFilter,
+		netflowFilter:  g.netflowFilter,
+		qingpingFilter: g.qingpingFilter,
+	}
+}
+
+// closeOutputGeneration waits for every processPacket call still holding
+// old open (old.refs) to finish, then closes whichever of old's sinks
+// aren't carried over into newGen. Meant to run in its own goroutine: refs
+// draining can take as long as the slowest in-flight export.
+func closeOutputGeneration(old, newGen *outputGeneration) {
+	if old == nil {
+		return
+	}
+	old.refs.Wait()
+
+	if old.fileWriter != nil && old.fileWriter != newGen.fileWriter {
+		old.fileWriter.Close()
+	}
+	if old.pcapWriter != nil && old.pcapWriter != newGen.pcapWriter {
+		old.pcapWriter.Close()
+	}
+	if old.netflowExp != nil && old.netflowExp != newGen.netflowExp {
+		old.netflowExp.Close()
+	}
+	if old.qingpingExp != nil && old.qingpingExp != newGen.qingpingExp {
+		old.qingpingExp.Close()
+	}
+	if old.anomalyEngine != nil && old.anomalyEngine != newGen.anomalyEngine {
+		old.anomalyEngine.Close()
+	}
+	if old.reassemblyMgr != nil && old.reassemblyMgr != newGen.reassemblyMgr {
+		old.reassemblyMgr.Close()
+	}
+	if old.sessionTracker != nil && old.sessionTracker != newGen.sessionTracker {
+		old.sessionTracker.Close()
+	}
 }
 
 // Config contains server configuration
 type Config struct {
-	ListenAddr  string
-	BufferSize  int
-	FileWriter  *output.FileWriter
-	PcapWriter  *pcap.Writer
-	NetFlowExp  *netflow.Exporter
-	QingPingExp *qingping.Exporter
-	Logger      *logger.Logger
+	ListenAddr            string
+	BufferSize            int
+	FileWriter            *output.FileWriter
+	PcapWriter            *pcap.Writer
+	NetFlowExp            *netflow.Exporter
+	QingPingExp           *qingping.Exporter
+	AnomalyEngine         *anomaly.Engine
+	Parsers               *parsers.Registry
+	SessionTracker        *session.Tracker
+	ReassemblyIdleTimeout time.Duration
+	// FileFilter, PcapFilter, NetflowFilter and QingpingFilter gate
+	// whether a decoded packet reaches the matching sink. A nil filter
+	// matches everything.
+	FileFilter     *filter.Filter
+	PcapFilter     *filter.Filter
+	NetflowFilter  *filter.Filter
+	QingpingFilter *filter.Filter
+	// GlobalFilter, DenySourceIPs and SampleRate gate every packet before
+	// it's decoded or handed to any output; see Server.shouldProcess.
+	GlobalFilter  *filter.Filter
+	DenySourceIPs []string
+	SampleRate    int
+	// MetricsAddr, if non-empty, serves Prometheus metrics at
+	// http://MetricsAddr/metrics for the lifetime of the server.
+	MetricsAddr string
+	// Workers, QueueDepth and OverflowPolicy configure the packet
+	// pipeline: Workers worker goroutines drain a queue of size
+	// QueueDepth filled by the UDP read loop. Zero values fall back to
+	// defaultWorkers/defaultQueueDepth/OverflowBlock (see pipeline.go).
+	Workers        int
+	QueueDepth     int
+	OverflowPolicy OverflowPolicy
+	// ReassemblyMgr and ReassemblyWriter back a standalone TCP stream
+	// reassembly/fingerprinting output that runs over every TCP flow
+	// (see internal/reassembly); nil disables it. Unlike the other
+	// sinks, this pair isn't swapped by ReplaceOutputs.
+	ReassemblyMgr    *reassembly.Manager
+	ReassemblyWriter reassembly.Writer
+	Logger           *logger.Logger
 }
 
 // NewServer creates a new TZSP server
 func NewServer(cfg *Config) *Server {
-	return &Server{
-		listenAddr:    cfg.ListenAddr,
-		bufferSize:    cfg.BufferSize,
-		tzspDecoder:   tzsp.NewDecoder(),
-		packetDecoder: decoder.NewDecoder(),
-		fileWriter:    cfg.FileWriter,
-		pcapWriter:    cfg.PcapWriter,
-		netflowExp:    cfg.NetFlowExp,
-		qingpingExp:   cfg.QingPingExp,
-		logger:        cfg.Logger,
+	s := &Server{
+		listenAddr:       cfg.ListenAddr,
+		bufferSize:       cfg.BufferSize,
+		metricsAddr:      cfg.MetricsAddr,
+		tzspDecoder:      tzsp.NewDecoder(),
+		packetDecoder:    decoder.NewDecoder(),
+		logger:           cfg.Logger,
+		etherTypeCounts:  make(map[uint16]uint64),
+		l4ProtocolCounts: make(map[string]uint64),
+		metrics:          metrics.New(),
+		workers:          cfg.Workers,
+		queueDepth:       cfg.QueueDepth,
+		overflowPolicy:   cfg.OverflowPolicy,
+	}
+
+	s.gen = &outputGeneration{
+		fileWriter:     cfg.FileWriter,
+		pcapWriter:     cfg.PcapWriter,
+		netflowExp:     cfg.NetFlowExp,
+		qingpingExp:    cfg.QingPingExp,
+		anomalyEngine:  cfg.AnomalyEngine,
+		parsers:        cfg.Parsers,
+		sessionTracker: cfg.SessionTracker,
+		reassemblyMgr:  s.newReassemblyManager(cfg.QingPingExp, cfg.ReassemblyIdleTimeout),
+		fileFilter:     cfg.FileFilter,
+		pcapFilter:     cfg.PcapFilter,
+		netflowFilter:  cfg.NetflowFilter,
+		qingpingFilter: cfg.QingpingFilter,
+	}
+	s.globalFilter = cfg.GlobalFilter
+	s.denySourceIPs = denySourceIPSet(cfg.DenySourceIPs)
+	s.sampleRate = cfg.SampleRate
+
+	s.reassemblyOutputMgr = cfg.ReassemblyMgr
+	s.reassemblyWriter = cfg.ReassemblyWriter
+	if s.reassemblyOutputMgr != nil && s.reassemblyWriter != nil {
+		go s.forwardReassemblyOutput(s.reassemblyOutputMgr, s.reassemblyWriter)
 	}
+
+	return s
+}
+
+// newReassemblyManager builds a reassembly.Manager and starts forwarding
+// its completed messages, but only when QingPing export is enabled.
+func (s *Server) newReassemblyManager(qingpingExp *qingping.Exporter, idleTimeout time.Duration) *reassembly.Manager {
+	if qingpingExp == nil {
+		return nil
+	}
+	mgr := reassembly.NewManager(reassembly.Config{IdleTimeout: idleTimeout, EnableHTTP: true})
+	go s.forwardReassembledMessages(mgr, qingpingExp)
+	return mgr
+}
+
+// ReplaceOutputs atomically swaps the output sinks and their bpf_filter
+// expressions, e.g. after a config reload, without dropping packets
+// already in flight through processPacket. Sinks being replaced are closed
+// only once the last processPacket call still holding the old generation
+// (see outputGeneration.refs) has released it, so a worker mid-export
+// against an old sink is never closed out from under it.
+func (s *Server) ReplaceOutputs(fileWriter *output.FileWriter, pcapWriter *pcap.Writer, netflowExp *netflow.Exporter, qingpingExp *qingping.Exporter, anomalyEngine *anomaly.Engine, parserRegistry *parsers.Registry, sessionTracker *session.Tracker, reassemblyIdleTimeout time.Duration, fileFilter, pcapFilter, netflowFilter, qingpingFilter, globalFilter *filter.Filter, denySourceIPs []string, sampleRate int) {
+	newGen := &outputGeneration{
+		fileWriter:     fileWriter,
+		pcapWriter:     pcapWriter,
+		netflowExp:     netflowExp,
+		qingpingExp:    qingpingExp,
+		anomalyEngine:  anomalyEngine,
+		parsers:        parserRegistry,
+		sessionTracker: sessionTracker,
+		reassemblyMgr:  s.newReassemblyManager(qingpingExp, reassemblyIdleTimeout),
+		fileFilter:     fileFilter,
+		pcapFilter:     pcapFilter,
+		netflowFilter:  netflowFilter,
+		qingpingFilter: qingpingFilter,
+	}
+
+	s.outputsMu.Lock()
+	oldGen := s.gen
+	s.gen = newGen
+	s.globalFilter = globalFilter
+	s.denySourceIPs = denySourceIPSet(denySourceIPs)
+	s.sampleRate = sampleRate
+	s.outputsMu.Unlock()
+
+	go closeOutputGeneration(oldGen, newGen)
+}
+
+// SetFilter replaces the global pre-filter evaluated against every packet
+// before it's decoded or handed to any output (see shouldProcess), e.g.
+// from an operator-facing control interface. A nil filter matches
+// everything.
+func (s *Server) SetFilter(f *filter.Filter) {
+	s.outputsMu.Lock()
+	s.globalFilter = f
+	s.outputsMu.Unlock()
+}
+
+// denySourceIPSet builds a lookup set from a list of IP strings, or nil
+// if ips is empty so the common case (no deny-list configured) doesn't
+// allocate.
+func denySourceIPSet(ips []string) map[string]struct{} {
+	if len(ips) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(ips))
+	for _, ip := range ips {
+		set[ip] = struct{}{}
+	}
+	return set
+}
+
+// sourceIP extracts the encapsulated packet's IPv4/IPv6 source address,
+// or nil if it has neither layer.
+func sourceIP(data []byte) net.IP {
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		return ipLayer.(*layers.IPv4).SrcIP
+	}
+	if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		return ipLayer.(*layers.IPv6).SrcIP
+	}
+	return nil
+}
+
+// shouldProcess decides, once per packet and ahead of any decode or
+// output, whether data is worth continuing to process: it must pass the
+// global bpf_filter, not come from a denied source IP, and survive
+// sampling. Unlike fileFilter/pcapFilter/etc., which gate one sink each,
+// a packet rejected here skips decode and every output.
+func (s *Server) shouldProcess(data []byte) bool {
+	s.outputsMu.RLock()
+	globalFilter := s.globalFilter
+	denySourceIPs := s.denySourceIPs
+	sampleRate := s.sampleRate
+	s.outputsMu.RUnlock()
+
+	if !globalFilter.Matches(data) {
+		return false
+	}
+
+	if len(denySourceIPs) > 0 {
+		if ip := sourceIP(data); ip != nil {
+			if _, denied := denySourceIPs[ip.String()]; denied {
+				return false
+			}
+		}
+	}
+
+	if sampleRate > 1 {
+		n := atomic.AddUint64(&s.sampleCounter, 1)
+		if n%uint64(sampleRate) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RotatePCAP forces the current PCAP writer to roll over immediately
+// regardless of size, e.g. from a control command, or reports an error
+// if PCAP output isn't configured.
+func (s *Server) RotatePCAP() error {
+	s.outputsMu.RLock()
+	pcapWriter := s.gen.pcapWriter
+	s.outputsMu.RUnlock()
+	if pcapWriter == nil {
+		return fmt.Errorf("PCAP output is not enabled")
+	}
+	return pcapWriter.Rotate()
+}
+
+// SetNetflowExporter swaps the NetFlow sink directly, e.g. from a control
+// command, closing the previous one once the last processPacket call still
+// using it releases it (see outputGeneration.refs).
+func (s *Server) SetNetflowExporter(exp *netflow.Exporter) {
+	s.outputsMu.Lock()
+	old := s.gen
+	newGen := old.clone()
+	newGen.netflowExp = exp
+	s.gen = newGen
+	s.outputsMu.Unlock()
+	go closeOutputGeneration(old, newGen)
+}
+
+// SetQingpingExporter swaps the QingPing sink directly, e.g. from a
+// control command, closing the previous one once the last processPacket
+// call still using it releases it (see outputGeneration.refs).
+func (s *Server) SetQingpingExporter(exp *qingping.Exporter) {
+	s.outputsMu.Lock()
+	old := s.gen
+	newGen := old.clone()
+	newGen.qingpingExp = exp
+	s.gen = newGen
+	s.outputsMu.Unlock()
+	go closeOutputGeneration(old, newGen)
+}
+
+// Stats summarizes the server's packet counters, e.g. for a control
+// command, in the same form as the periodic statistics log line.
+func (s *Server) Stats() string {
+	received := atomic.LoadUint64(&s.packetsReceived)
+	decoded := atomic.LoadUint64(&s.packetsDecoded)
+	written := atomic.LoadUint64(&s.packetsWritten)
+
+	var decodeRate float64
+	if received > 0 {
+		decodeRate = float64(decoded) / float64(received) * 100
+	}
+	return fmt.Sprintf("packets_received=%d packets_decoded=%d packets_written=%d decode_rate=%.1f%%",
+		received, decoded, written, decodeRate)
 }
 
 // Start starts the TZSP server
@@ -79,32 +438,46 @@ func (s *Server) Start(ctx context.Context) error {
 	s.conn = conn
 	s.logger.Info("[OK] UDP socket opened", "port", addr.Port)
 
+	if s.metricsAddr != "" {
+		metricsSrv, err := metrics.Serve(s.metricsAddr, s.metrics)
+		if err != nil {
+			return fmt.Errorf("failed to start metrics listener: %w", err)
+		}
+		s.metricsServer = metricsSrv
+		s.logger.Info("[OK] Metrics endpoint listening", "address", s.metricsAddr)
+	}
+
 	s.logger.Info("========================================")
 	s.logger.Info("*** TZSP server is now listening for packets ***")
 	s.logger.Info("========================================")
 	s.logger.Info("Configuration:")
 	s.logger.Info("  - Listen address:", "addr", s.listenAddr)
 	s.logger.Info("  - Buffer size:", "bytes", s.bufferSize)
-	if s.fileWriter != nil {
+	if s.gen.fileWriter != nil {
 		s.logger.Info("  - File output (packet metadata): ENABLED")
 	} else {
 		s.logger.Info("  - File output (packet metadata): disabled")
 	}
-	if s.pcapWriter != nil {
+	if s.gen.pcapWriter != nil {
 		s.logger.Info("  - PCAP output: ENABLED")
 	} else {
 		s.logger.Info("  - PCAP output: disabled")
 	}
-	if s.netflowExp != nil {
+	if s.gen.netflowExp != nil {
 		s.logger.Info("  - NetFlow export: ENABLED")
 	} else {
 		s.logger.Info("  - NetFlow export: disabled")
 	}
-	if s.qingpingExp != nil {
+	if s.gen.qingpingExp != nil {
 		s.logger.Info("  - QingPing export: ENABLED")
 	} else {
 		s.logger.Info("  - QingPing export: disabled")
 	}
+	if s.gen.anomalyEngine != nil {
+		s.logger.Info("  - Anomaly capture: ENABLED")
+	} else {
+		s.logger.Info("  - Anomaly capture: disabled")
+	}
 	s.logger.Info("========================================")
 	s.logger.Info("Waiting for TZSP packets... (Press Ctrl+C to stop)")
 	s.logger.Info("========================================")
@@ -112,19 +485,32 @@ func (s *Server) Start(ctx context.Context) error {
 	// Start statistics reporter
 	go s.reportStats(ctx)
 
+	// Start the packet pipeline: ReadFromUDP below only enqueues buffers,
+	// s.workers worker goroutines do the actual decode/export work.
+	s.pipe = newPipeline(s, s.workers, s.queueDepth, s.overflowPolicy)
+	workers := s.workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	s.pipe.start(workers)
+	s.logger.Info("[OK] Packet pipeline started", "workers", workers, "queue_depth", cap(s.pipe.queue), "overflow_policy", s.pipe.policy)
+
 	// Main receive loop
-	buf := make([]byte, s.bufferSize)
 	for {
 		select {
 		case <-ctx.Done():
 			s.logger.Info("Context cancelled, stopping receiver loop...")
+			s.pipe.stop()
 			return nil
 		default:
 			// Set read deadline to allow checking context
 			s.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
 
+			buf := s.pipe.getBuffer()
+			readStart := time.Now()
 			n, remoteAddr, err := s.conn.ReadFromUDP(buf)
 			if err != nil {
+				s.pipe.putBuffer(buf)
 				// Check if it's a timeout (expected)
 				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 					continue
@@ -132,26 +518,38 @@ func (s *Server) Start(ctx context.Context) error {
 				s.logger.Error("Failed to read UDP packet", "error", err)
 				continue
 			}
+			s.metrics.UDPReadLatency.Observe(time.Since(readStart).Seconds())
 
-			s.packetsReceived++
+			received := atomic.AddUint64(&s.packetsReceived, 1)
+			s.metrics.PacketsReceived.WithLabelValues(remoteAddr.IP.String()).Inc()
+			s.metrics.BytesReceived.WithLabelValues(remoteAddr.IP.String()).Add(float64(n))
 
 			// Log first packet received
-			if s.packetsReceived == 1 {
+			if received == 1 {
 				s.logger.Info(">>> First TZSP packet received!",
 					"source", remoteAddr.String(),
 					"size", n)
 			}
 
-			// Process packet
-			if err := s.processPacket(buf[:n], remoteAddr.String()); err != nil {
-				s.logger.Debug("Failed to process packet", "error", err, "source", remoteAddr.String())
-			}
+			s.pipe.enqueue(ctx, &packetJob{buf: buf, n: n, remoteAddr: remoteAddr.String(), recvTime: time.Now()})
 		}
 	}
 }
 
+// ListenConfigChanged reports whether addr or bufferSize differ from the
+// values the listener was started with. Changing either requires
+// restarting the UDP socket, which ReplaceOutputs deliberately does not
+// do, so callers should surface this to the operator instead of silently
+// ignoring the change.
+func (s *Server) ListenConfigChanged(addr string, bufferSize int) bool {
+	return addr != s.listenAddr || bufferSize != s.bufferSize
+}
+
 // Stop stops the TZSP server
 func (s *Server) Stop() error {
+	if s.metricsServer != nil {
+		s.metricsServer.Close()
+	}
 	if s.conn != nil {
 		return s.conn.Close()
 	}
@@ -163,6 +561,7 @@ func (s *Server) processPacket(data []byte, sourceAddr string) error {
 	// Decode TZSP packet
 	tzspPkt, err := s.tzspDecoder.Decode(data, sourceAddr)
 	if err != nil {
+		s.metrics.DecodeErrors.WithLabelValues("tzsp").Inc()
 		return fmt.Errorf("TZSP decode error: %w", err)
 	}
 
@@ -171,7 +570,14 @@ func (s *Server) processPacket(data []byte, sourceAddr string) error {
 		return nil
 	}
 
-	s.packetsDecoded++
+	// Apply the global bpf_filter, source-IP deny-list and sampling rate
+	// before decoding or exporting anything, so a rejected packet costs
+	// no more than this check.
+	if !s.shouldProcess(tzspPkt.EncapPacket) {
+		return nil
+	}
+
+	atomic.AddUint64(&s.packetsDecoded, 1)
 
 	// Use TZSP timestamp if available, otherwise use receive time
 	timestamp := tzspPkt.ReceivedTime
@@ -187,12 +593,37 @@ func (s *Server) processPacket(data []byte, sourceAddr string) error {
 		"timestamp", timestamp.Format(time.RFC3339Nano),
 	)
 
-	// Write to PCAP if enabled
-	if s.pcapWriter != nil {
-		if err := s.pcapWriter.WritePacket(tzspPkt.EncapPacket, timestamp); err != nil {
+	// Snapshot the output generation so a concurrent ReplaceOutputs (config
+	// reload) can't swap its sinks out from under the rest of this call;
+	// gen.refs holds it open until release, so closeOutputGeneration won't
+	// close anything this call is still using.
+	s.outputsMu.RLock()
+	gen := s.gen
+	s.outputsMu.RUnlock()
+	gen.refs.Add(1)
+	defer gen.refs.Done()
+
+	fileWriter := gen.fileWriter
+	pcapWriter := gen.pcapWriter
+	netflowExp := gen.netflowExp
+	qingpingExp := gen.qingpingExp
+	anomalyEngine := gen.anomalyEngine
+	parserRegistry := gen.parsers
+	sessionTracker := gen.sessionTracker
+	reassemblyMgr := gen.reassemblyMgr
+	fileFilter := gen.fileFilter
+	pcapFilter := gen.pcapFilter
+	netflowFilter := gen.netflowFilter
+	qingpingFilter := gen.qingpingFilter
+
+	// Write to PCAP if enabled and the packet passes its bpf_filter
+	if pcapWriter != nil && pcapFilter.Matches(tzspPkt.EncapPacket) {
+		if err := pcapWriter.WriteTZSPPacket(tzspPkt, tzspPkt.ReceivedTime); err != nil {
 			s.logger.Error("Failed to write PCAP", "error", err)
+			s.metrics.ExportFailure.WithLabelValues("pcap").Inc()
 		} else {
-			s.packetsWritten++
+			atomic.AddUint64(&s.packetsWritten, 1)
+			s.metrics.ExportSuccess.WithLabelValues("pcap").Inc()
 		}
 	}
 
@@ -201,31 +632,133 @@ func (s *Server) processPacket(data []byte, sourceAddr string) error {
 	if err != nil {
 		// Log decode errors at debug level (they're common for non-IP packets)
 		s.logger.Debug("Packet decode error", "error", err)
+		s.metrics.DecodeErrors.WithLabelValues("l3").Inc()
 		return nil
 	}
 
-	// Export to NetFlow if enabled
-	if s.netflowExp != nil {
-		if err := s.netflowExp.ProcessPacket(packetInfo); err != nil {
+	s.recordPacketStats(packetInfo)
+
+	// Feed every TCP packet into the standalone reassembly/fingerprinting
+	// output, independent of whether QingPing export is enabled.
+	if packetInfo.Protocol == "TCP" && s.reassemblyOutputMgr != nil {
+		if err := s.reassemblyOutputMgr.AssemblePacket(tzspPkt.EncapPacket, timestamp); err != nil {
+			s.logger.Debug("Failed to feed packet into reassembly output", "error", err)
+		}
+	}
+
+	// Hand the payload to whichever L7 parser is registered for this
+	// flow's protocol/port, if any, purely for observability: extracted
+	// fields are logged at debug level and, when a session tracker is set,
+	// attached to the flow's session span; they feed no other output.
+	if parserRegistry != nil && len(packetInfo.Payload) > 0 {
+		s.runParser(parserRegistry, sessionTracker, packetInfo)
+	}
+
+	// Evaluate anomaly capture triggers unconditionally: they're not gated
+	// by any output's bpf_filter since they decide for themselves, per
+	// trigger, what's worth keeping evidence for.
+	if anomalyEngine != nil {
+		if matches := anomalyEngine.Evaluate(packetInfo); len(matches) > 0 && netflowExp != nil {
+			netflowExp.FlagAnomaly(packetInfo.SrcIP, packetInfo.SrcPort, packetInfo.DstIP, packetInfo.DstPort, packetInfo.Protocol, packetInfo.ToS)
+		}
+	}
+
+	// Export to NetFlow if enabled and the packet passes its bpf_filter
+	if netflowExp != nil && netflowFilter.Matches(tzspPkt.EncapPacket) {
+		if err := netflowExp.ProcessPacket(packetInfo); err != nil {
 			s.logger.Error("Failed to export NetFlow", "error", err)
+			s.metrics.ExportFailure.WithLabelValues("netflow").Inc()
+		} else {
+			s.metrics.ExportSuccess.WithLabelValues("netflow").Inc()
 		}
 	}
 
-	// Export to QingPing if enabled
-	if s.qingpingExp != nil {
-		if err := s.qingpingExp.Export(packetInfo); err != nil {
+	// Export to QingPing if enabled and the packet passes its bpf_filter
+	if qingpingExp != nil && qingpingFilter.Matches(tzspPkt.EncapPacket) {
+		if err := qingpingExp.Export(packetInfo); err != nil {
 			s.logger.Error("Failed to export QingPing", "error", err)
+			s.metrics.ExportFailure.WithLabelValues("qingping").Inc()
+		} else {
+			s.metrics.ExportSuccess.WithLabelValues("qingping").Inc()
+		}
+
+		if packetInfo.Protocol == "TCP" && reassemblyMgr != nil {
+			if err := reassemblyMgr.AssemblePacket(tzspPkt.EncapPacket, timestamp); err != nil {
+				s.logger.Debug("Failed to feed packet into TCP reassembly", "error", err)
+			}
 		}
 	}
 
-	// Write packet metadata to file if enabled
-	if s.fileWriter != nil {
-		s.fileWriter.WritePacket(packetInfo)
+	// Write packet metadata to file if enabled and the packet passes its bpf_filter
+	if fileWriter != nil && fileFilter.Matches(tzspPkt.EncapPacket) {
+		fileWriter.WritePacket(packetInfo)
+		s.metrics.ExportSuccess.WithLabelValues("file").Inc()
 	}
 
 	return nil
 }
 
+// recordPacketStats updates the per-EtherType and per-L4-protocol
+// breakdown reported alongside the existing packet counters.
+func (s *Server) recordPacketStats(info *decoder.PacketInfo) {
+	s.statsMu.Lock()
+	s.etherTypeCounts[info.EtherType]++
+	s.l4ProtocolCounts[info.Protocol]++
+	s.statsMu.Unlock()
+
+	protoLabel := info.Protocol
+	switch protoLabel {
+	case "TCP", "UDP", "ICMP":
+	default:
+		protoLabel = "other"
+	}
+	s.metrics.PacketsByProto.WithLabelValues(strings.ToLower(protoLabel)).Inc()
+}
+
+// runParser looks up the L7 parser registered for info's protocol and
+// destination port (falling back to the source port, in case info is
+// carrying the response leg of the flow) and feeds it info's payload.
+// Directional reassembly is keyed on the literal (as-seen) 5-tuple, since
+// request and response bytes travel in opposite directions; the
+// correlated session, spanning both legs, is tracked separately by
+// tracker (see internal/session) when non-nil.
+func (s *Server) runParser(reg *parsers.Registry, tracker *session.Tracker, info *decoder.PacketInfo) {
+	isRequest := true
+	parser, ok := reg.Lookup(info.Protocol, info.DstPort)
+	if !ok {
+		isRequest = false
+		parser, ok = reg.Lookup(info.Protocol, info.SrcPort)
+		if !ok {
+			return
+		}
+	}
+
+	flowKey := fmt.Sprintf("%s:%d-%s:%d-%s", info.SrcIP, info.SrcPort, info.DstIP, info.DstPort, info.Protocol)
+	_, fields, err := parser.Parse(flowKey, isRequest, info.Payload)
+	if err != nil {
+		s.logger.Debug("L7 parser failed, dropping session state", "parser", parser.Name(), "flow", flowKey, "error", err)
+		return
+	}
+	if fields == nil {
+		return
+	}
+	s.logger.Debug("L7 parser extracted fields", "parser", parser.Name(), "flow", flowKey, "fields", fields)
+
+	if tracker == nil {
+		return
+	}
+	sessKey := session.Key(info.SrcIP, info.SrcPort, info.DstIP, info.DstPort, info.Protocol)
+	packetTime := time.Unix(0, info.Timestamp)
+	if isRequest {
+		tracker.Request(sessKey, parser.Name(), fields, info.Payload, packetTime)
+	} else {
+		tracker.Trace(sessKey, fields)
+		if sess := tracker.Response(sessKey, info.Payload, packetTime); sess != nil {
+			s.logger.Debug("Session completed", "service", sess.Service, "flow", sessKey, "latency", sess.Latency())
+		}
+	}
+}
+
 // reportStats periodically reports server statistics
 func (s *Server) reportStats(ctx context.Context) {
 	ticker := time.NewTicker(30 * time.Second)
@@ -236,12 +769,86 @@ func (s *Server) reportStats(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			received := atomic.LoadUint64(&s.packetsReceived)
+			decoded := atomic.LoadUint64(&s.packetsDecoded)
+			written := atomic.LoadUint64(&s.packetsWritten)
 			s.logger.Info("=== Statistics Report ===",
-				"packets_received", s.packetsReceived,
-				"packets_decoded", s.packetsDecoded,
-				"packets_written", s.packetsWritten,
-				"decode_rate", fmt.Sprintf("%.1f%%", float64(s.packetsDecoded)/float64(s.packetsReceived)*100),
+				"packets_received", received,
+				"packets_decoded", decoded,
+				"packets_written", written,
+				"decode_rate", fmt.Sprintf("%.1f%%", float64(decoded)/float64(received)*100),
+			)
+
+			s.statsMu.Lock()
+			etherTypeCounts := make(map[uint16]uint64, len(s.etherTypeCounts))
+			for k, v := range s.etherTypeCounts {
+				etherTypeCounts[k] = v
+			}
+			l4ProtocolCounts := make(map[string]uint64, len(s.l4ProtocolCounts))
+			for k, v := range s.l4ProtocolCounts {
+				l4ProtocolCounts[k] = v
+			}
+			s.statsMu.Unlock()
+			s.logger.Info("=== Protocol Breakdown ===",
+				"by_ether_type", etherTypeCounts,
+				"by_l4_protocol", l4ProtocolCounts,
 			)
+
+			// reassemblyMgr/reassemblyOutputMgr expire their own idle
+			// streams on a loop driven by their own IdleTimeout (see
+			// reassembly.Manager.idleLoop); nothing to do for them here.
+			s.outputsMu.RLock()
+			sessionTracker := s.gen.sessionTracker
+			s.outputsMu.RUnlock()
+			if sessionTracker != nil {
+				if expired := sessionTracker.ExpireOlderThan(time.Now().Add(-30 * time.Second)); len(expired) > 0 {
+					s.logger.Debug("Expired idle sessions", "count", len(expired))
+				}
+			}
 		}
 	}
 }
+
+// forwardReassembledMessages drains completed HTTP messages recovered by
+// mgr and hands request bodies to qingpingExp. It exits once mgr's
+// message channel is closed, which happens when mgr is replaced or the
+// server shuts down.
+func (s *Server) forwardReassembledMessages(mgr *reassembly.Manager, qingpingExp *qingping.Exporter) {
+	for msg := range mgr.Messages() {
+		if !msg.IsRequest || len(msg.Body) == 0 {
+			continue
+		}
+		if err := qingpingExp.ExportHTTPMessage(msg.FlowKey, msg.Method, msg.URL, msg.Body); err != nil {
+			s.logger.Error("Failed to export reassembled HTTP message to QingPing", "error", err, "flow", msg.FlowKey)
+		}
+	}
+}
+
+// forwardReassemblyOutput drains mgr's HTTPMessage and TLSFingerprint
+// channels concurrently, handing each to writer, until both are closed
+// (which happens when the server shuts down; this manager is never
+// replaced mid-run).
+func (s *Server) forwardReassemblyOutput(mgr *reassembly.Manager, writer reassembly.Writer) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for msg := range mgr.Messages() {
+			if err := writer.WriteHTTPMessage(msg); err != nil {
+				s.logger.Error("Failed to write reassembled HTTP message", "error", err, "flow", msg.FlowKey)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for fp := range mgr.Fingerprints() {
+			if err := writer.WriteTLSFingerprint(fp); err != nil {
+				s.logger.Error("Failed to write TLS fingerprint", "error", err, "flow", fp.FlowKey)
+			}
+		}
+	}()
+
+	wg.Wait()
+}
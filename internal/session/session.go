@@ -0,0 +1,160 @@
+// Package session tracks bidirectional flows across their request and
+// response legs, correlating them into a single Session keyed on the
+// canonicalized 5-tuple (srcIP, dstIP, srcPort, dstPort, proto) so both
+// directions of a conversation collapse to one entry. Each Session spans
+// an OpenTelemetry-style trace (see Tracer) from first request bytes to
+// the matching response, or to idle expiry.
+package session
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Session holds one flow's accumulated request/response state between
+// Tracker.Request and either Tracker.Response or expiry.
+type Session struct {
+	Key         string
+	ReqTime     time.Time
+	RespTime    time.Time
+	ReqBuf      []byte
+	RespBuf     []byte
+	Service     string
+	GeneralInfo map[string]string
+	Traces      []map[string]string
+
+	span Span
+}
+
+// Latency reports how long the response took to arrive, or zero if the
+// session has no response yet.
+func (s *Session) Latency() time.Duration {
+	if s.RespTime.IsZero() {
+		return 0
+	}
+	return s.RespTime.Sub(s.ReqTime)
+}
+
+// Tracker correlates request/response bytes into Sessions and starts/ends
+// a Span for each one. It is safe for concurrent use.
+type Tracker struct {
+	tracer      Tracer
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewTracker creates a Tracker. idleTimeout bounds how long a session can
+// sit without a matching response before ExpireOlderThan reclaims it.
+func NewTracker(tracer Tracer, idleTimeout time.Duration) *Tracker {
+	return &Tracker{
+		tracer:      tracer,
+		idleTimeout: idleTimeout,
+		sessions:    make(map[string]*Session),
+	}
+}
+
+// Key canonicalizes a 5-tuple so both directions of a conversation map to
+// the same Session.
+func Key(srcIP string, srcPort uint16, dstIP string, dstPort uint16, proto string) string {
+	a := fmt.Sprintf("%s:%d", srcIP, srcPort)
+	b := fmt.Sprintf("%s:%d", dstIP, dstPort)
+	if b < a {
+		a, b = b, a
+	}
+	return fmt.Sprintf("%s-%s-%s", a, b, proto)
+}
+
+// Request records request bytes for key, starting a new Session (and its
+// Span) on first sight. service and generalInfo tag the span's starting
+// attributes; generalInfo may be nil.
+func (t *Tracker) Request(key, service string, generalInfo map[string]string, data []byte, now time.Time) *Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sess, ok := t.sessions[key]
+	if !ok {
+		_, span := t.tracer.Start(context.Background(), service, generalInfo)
+		sess = &Session{
+			Key:         key,
+			ReqTime:     now,
+			Service:     service,
+			GeneralInfo: generalInfo,
+			span:        span,
+		}
+		t.sessions[key] = sess
+	}
+	sess.ReqBuf = append(sess.ReqBuf, data...)
+	return sess
+}
+
+// Response records response bytes for key and, if a matching in-flight
+// session exists, closes it out: stamps RespTime, ends its Span, and
+// removes it from the tracker. It returns the completed Session, or nil
+// if no session was open for key.
+func (t *Tracker) Response(key string, data []byte, now time.Time) *Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sess, ok := t.sessions[key]
+	if !ok {
+		return nil
+	}
+	sess.RespBuf = append(sess.RespBuf, data...)
+	sess.RespTime = now
+	delete(t.sessions, key)
+
+	sess.span.SetAttribute("latency", sess.Latency().String())
+	sess.span.End()
+
+	return sess
+}
+
+// Trace appends fields extracted for an in-flight session (e.g. by
+// internal/parsers) as one more entry in its Traces, tagging them onto
+// the session's Span too. It's a no-op if no session is open for key.
+func (t *Tracker) Trace(key string, fields map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sess, ok := t.sessions[key]
+	if !ok {
+		return
+	}
+	sess.Traces = append(sess.Traces, fields)
+	for k, v := range fields {
+		sess.span.SetAttribute(k, v)
+	}
+}
+
+// ExpireOlderThan ends and removes every session whose ReqTime is before
+// cutoff and which never saw a response, returning them so the caller can
+// log or export them as timed-out.
+func (t *Tracker) ExpireOlderThan(cutoff time.Time) []*Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var expired []*Session
+	for key, sess := range t.sessions {
+		if sess.ReqTime.Before(cutoff) {
+			sess.span.SetAttribute("expired", "true")
+			sess.span.End()
+			expired = append(expired, sess)
+			delete(t.sessions, key)
+		}
+	}
+	return expired
+}
+
+// Close releases resources held by the underlying Tracer, e.g. flushing
+// pending spans and closing an OTLPTracer's collector connection. A
+// no-op for tracers (like logTracer) that need no cleanup.
+func (t *Tracker) Close() error {
+	if shutdowner, ok := t.tracer.(interface{ Shutdown(context.Context) error }); ok {
+		return shutdowner.Shutdown(context.Background())
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package session
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pavelkim/tzsp_server/internal/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Span is the subset of an OpenTelemetry span that Tracker needs: tag it
+// with request/response metadata as they become available, then end it.
+type Span interface {
+	SetAttribute(key, value string)
+	End()
+}
+
+// Tracer starts a Span for a new session.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs map[string]string) (context.Context, Span)
+}
+
+// logTracer is the fallback Tracer used when output.tracing.otlp_endpoint
+// isn't set: it emits start/attribute/end events to a logger.Logger
+// instead of an OTLP collector.
+type logTracer struct {
+	logger *logger.Logger
+}
+
+// NewLogTracer creates a Tracer that records spans as structured log
+// events through log.
+func NewLogTracer(log *logger.Logger) Tracer {
+	return &logTracer{logger: log}
+}
+
+func (t *logTracer) Start(ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+	t.logger.Debug("session span started", "span", name, "attrs", attrs)
+	return ctx, &logSpan{logger: t.logger, name: name}
+}
+
+// logSpan backs logTracer's spans.
+type logSpan struct {
+	logger     *logger.Logger
+	name       string
+	attributes map[string]string
+}
+
+func (s *logSpan) SetAttribute(key, value string) {
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+func (s *logSpan) End() {
+	s.logger.Debug("session span ended", "span", s.name, "attrs", s.attributes)
+}
+
+// OTLPTracer backs Tracer with real spans, exported in batches to an
+// OTLP/gRPC collector via go.opentelemetry.io/otel's SDK. Call Shutdown
+// when done with it to flush pending spans and close the collector
+// connection; Tracker.Close does this automatically.
+type OTLPTracer struct {
+	tracer   oteltrace.Tracer
+	provider *sdktrace.TracerProvider
+}
+
+// NewOTLPTracer dials endpoint (host:port, plaintext gRPC) and returns a
+// Tracer that exports every session span to it, tagged with
+// service.name=serviceName.
+func NewOTLPTracer(ctx context.Context, serviceName, endpoint string) (*OTLPTracer, error) {
+	exporter, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OTel resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	return &OTLPTracer{
+		tracer:   provider.Tracer("tzsp"),
+		provider: provider,
+	}, nil
+}
+
+func (t *OTLPTracer) Start(ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+	opts := make([]oteltrace.SpanStartOption, 0, len(attrs))
+	if len(attrs) > 0 {
+		kvs := make([]attribute.KeyValue, 0, len(attrs))
+		for k, v := range attrs {
+			kvs = append(kvs, attribute.String(k, v))
+		}
+		opts = append(opts, oteltrace.WithAttributes(kvs...))
+	}
+	ctx, span := t.tracer.Start(ctx, name, opts...)
+	return ctx, &otelSpan{span: span}
+}
+
+// Shutdown flushes pending spans and closes the collector connection.
+func (t *OTLPTracer) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
+
+// otelSpan backs OTLPTracer's spans.
+type otelSpan struct {
+	span oteltrace.Span
+}
+
+func (s *otelSpan) SetAttribute(key, value string) {
+	s.span.SetAttributes(attribute.String(key, value))
+}
+
+func (s *otelSpan) End() {
+	s.span.End()
+}
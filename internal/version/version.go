@@ -1,10 +1,60 @@
+// Package version holds build-time identity injected via -ldflags, so a
+// running binary (or its --version output) can be matched back to the
+// commit and release that produced it -- useful when several ARM edge
+// routers are running whatever got flashed onto them months apart.
 package version
 
-// Version is the current application version
-// This value is injected at build time using -ldflags
-var Version = "dev"
+import "runtime"
 
-// GetVersion returns the current application version
+// Version, Commit and BuildDate are injected at build time via
+// -ldflags "-X .../version.Version=... -X .../version.Commit=... -X .../version.BuildDate=...".
+// Locally built binaries (go build with no ldflags) fall back to "dev"/"unknown".
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// GetVersion returns the current application version.
 func GetVersion() string {
 	return Version
 }
+
+// GetCommit returns the git commit this binary was built from.
+func GetCommit() string {
+	return Commit
+}
+
+// GetBuildDate returns when this binary was built, as an RFC3339 string.
+func GetBuildDate() string {
+	return BuildDate
+}
+
+// GetGoVersion returns the Go toolchain version used to build this
+// binary (runtime.Version(), not a build-time injected value).
+func GetGoVersion() string {
+	return runtime.Version()
+}
+
+// Info bundles every build-identity field together, e.g. for --version
+// --json or a startup log line.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// GetInfo returns the full build Info.
+func GetInfo() Info {
+	return Info{
+		Version:   GetVersion(),
+		Commit:    GetCommit(),
+		BuildDate: GetBuildDate(),
+		GoVersion: GetGoVersion(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+}